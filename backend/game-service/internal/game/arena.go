@@ -0,0 +1,490 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// defaultMinPlayers is how many players an arena waits for when the config does not set it
+const defaultMinPlayers = 1
+
+// spawnClearance is how many free cells a new snake needs in front of its head
+const spawnClearance = 3
+
+// spawnAttempts bounds the search for a free spawn position
+const spawnAttempts = 200
+
+// Arena runs several snakes on one shared board
+// All snakes advance together on the arena's tick and can collide with each
+// other. With config.Teams set, snakes are spread across teams that share a
+// score. An arena waits for config.MinPlayers, counts down for config.Countdown
+// seconds, runs until every snake has died and then stays finished. On a
+// battle-royale board (config.ShrinkEvery) the last snake or team alive wins.
+// It provides thread-safe access like Game
+type Arena struct {
+	id          string            // Unique identifier of the arena
+	config      models.GameConfig // Game configuration parameters
+	mutex       sync.RWMutex      // Mutex to ensure thread-safe access to arena state
+	players     []*arenaPlayer    // Snakes in join order, which keeps updates deterministic
+	food        []models.FoodItem // Food shared by all snakes
+	obstacles   map[string]bool   // Wall cells from the selected map, keyed by pointKey
+	walls       []models.Point    // Wall cells from the selected map, as sent to clients
+	rng         *rand.Rand        // Per-arena random source so a seed replays the same food
+	seed        int64             // Seed of rng
+	status      models.RoomStatus // Lifecycle stage
+	countdown   int               // Ticks left before the arena starts while counting down
+	tick        uint64            // Ticks the snakes have moved since the arena started
+	winner      string            // Owner with the highest score when the arena ended
+	winningTeam string            // Team with the highest score when the arena ended
+	area        shrinker          // Playable area, which closes in on battle-royale boards
+}
+
+// arenaPlayer is one snake in an arena
+type arenaPlayer struct {
+	owner     string           // Player controlling the snake
+	body      []models.Point   // Segments, index 0 is the head
+	direction models.Direction // Current direction of travel
+	inputs    []input          // Turns waiting to be applied, one per tick
+	ack       uint64           // Sequence number of the last turn settled
+	growth    int              // Segments still to be added or removed
+	score     int              // Score from food eaten
+	alive     bool             // False once the snake has crashed
+	team      string           // Team the snake plays for; empty outside team mode
+	left      bool             // True once the owner has left a running arena
+}
+
+// NewArena creates an empty arena with the given configuration
+// Snakes are added with Join
+func NewArena(config models.GameConfig) *Arena {
+	config = normalizeConfig(config)
+	if config.MinPlayers <= 0 {
+		config.MinPlayers = defaultMinPlayers
+	}
+	seed := newSeed(config)
+	a := &Arena{
+		id:     newGameID(),
+		config: config,
+		rng:    rand.New(rand.NewSource(seed)),
+		seed:   seed,
+		status: models.RoomWaiting,
+		area:   newShrinker(config),
+	}
+	a.walls, a.obstacles = loadObstacles(config)
+	a.generateFood()
+	return a
+}
+
+// ID returns the arena's unique identifier
+func (a *Arena) ID() string {
+	return a.id
+}
+
+// Finished reports whether the arena has ended
+func (a *Arena) Finished() bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.status == models.RoomFinished
+}
+
+// Join adds a snake for the given owner at a free spot on the board
+// The snake faces toward the middle of the board so it does not start at a wall
+func (a *Arena) Join(owner string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.status == models.RoomFinished {
+		return fmt.Errorf("arena has finished")
+	}
+	if p := a.player(owner); p != nil && p.left {
+		return fmt.Errorf("player %q has already left the arena", owner)
+	} else if p != nil {
+		return fmt.Errorf("player %q is already in the arena", owner)
+	}
+	if a.config.MaxPlayers > 0 && a.present() >= a.config.MaxPlayers {
+		return fmt.Errorf("arena is full")
+	}
+
+	occupied := a.occupied()
+	bounds := a.area.bounds
+	for i := 0; i < spawnAttempts; i++ {
+		head := models.Point{X: bounds.X + a.rng.Intn(bounds.Width), Y: bounds.Y + a.rng.Intn(bounds.Height)}
+		dir := models.Right
+		if head.X >= bounds.X+bounds.Width/2 {
+			dir = models.Left
+		}
+
+		if a.clearAhead(head, dir, occupied) {
+			a.players = append(a.players, &arenaPlayer{
+				owner:     owner,
+				body:      []models.Point{head},
+				direction: dir,
+				alive:     true,
+				team:      a.assignTeam(),
+			})
+			a.updateStatus()
+			return nil
+		}
+	}
+	return fmt.Errorf("no room left in the arena")
+}
+
+// clearAhead reports whether head and the spawnClearance cells in front of it
+// are on the board and free
+func (a *Arena) clearAhead(head models.Point, dir models.Direction, occupied map[string]bool) bool {
+	p := head
+	for i := 0; i <= spawnClearance; i++ {
+		if !a.inBounds(p) || occupied[pointKey(p)] || a.obstacles[pointKey(p)] {
+			return false
+		}
+		p = nextPosition(p, dir)
+	}
+	return true
+}
+
+// updateStatus starts the countdown once enough players have joined, and
+// goes back to waiting if players leave before the arena starts
+// Callers must hold the mutex
+func (a *Arena) updateStatus() {
+	switch {
+	case a.status == models.RoomWaiting && len(a.players) >= a.config.MinPlayers:
+		a.status = models.RoomCountdown
+		a.countdown = a.countdownTicks()
+		if a.countdown == 0 {
+			a.status = models.RoomRunning
+		}
+	case a.status == models.RoomCountdown && len(a.players) < a.config.MinPlayers:
+		a.status = models.RoomWaiting
+		a.countdown = 0
+	}
+}
+
+// countdownTicks converts the configured countdown into arena ticks, rounding up
+func (a *Arena) countdownTicks() int {
+	ms := a.config.Countdown * 1000
+	return (ms + a.config.Speed - 1) / a.config.Speed
+}
+
+// Leave removes the owner's snake from the arena
+// Once the arena is running the player stays as a dead entry, so their score
+// still counts for their team and the final standings
+func (a *Arena) Leave(owner string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for i, p := range a.players {
+		if p.owner != owner {
+			continue
+		}
+		if a.status == models.RoomRunning || a.status == models.RoomFinished {
+			a.kill(p)
+			p.left = true
+			if a.status == models.RoomRunning {
+				a.checkFinished()
+			}
+			return
+		}
+		a.players = append(a.players[:i:i], a.players[i+1:]...)
+		a.updateStatus()
+		return
+	}
+}
+
+// present counts the players who have not left the arena
+// Callers must hold the mutex
+func (a *Arena) present() int {
+	n := 0
+	for _, p := range a.players {
+		if !p.left {
+			n++
+		}
+	}
+	return n
+}
+
+// SetDirection queues a turn for the owner's snake
+// Turns follow the same rules as Game.SetDirection and are acknowledged in
+// the snake's Ack
+func (a *Arena) SetDirection(owner string, dir models.Direction, seq uint64) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	p := a.player(owner)
+	if p == nil {
+		return fmt.Errorf("player %q is not in the arena", owner)
+	}
+
+	var settled uint64
+	if p.alive {
+		p.inputs, settled = queueTurn(p.inputs, p.direction, input{dir: dir, seq: seq})
+	} else {
+		p.inputs, settled = dropTurn(p.inputs, seq)
+	}
+	p.ack = max(p.ack, settled)
+	return nil
+}
+
+// player returns the owner's snake, or nil
+// Callers must hold the mutex
+func (a *Arena) player(owner string) *arenaPlayer {
+	for _, p := range a.players {
+		if p.owner == owner {
+			return p
+		}
+	}
+	return nil
+}
+
+// Update advances every living snake by one step
+// All snakes move at the same time. A snake dies when its new head hits a
+// wall, an obstacle, any snake's body, or another snake's new head
+// (head-to-head), including two heads swapping places. Teammates pass
+// through each other unless friendly fire is on.
+// Before the arena is running, Update only counts down
+func (a *Arena) Update() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	switch a.status {
+	case models.RoomCountdown:
+		a.countdown--
+		if a.countdown <= 0 {
+			a.status = models.RoomRunning
+		}
+		return
+	case models.RoomRunning:
+	default:
+		return
+	}
+
+	a.tick++
+
+	// A shrinking board closes in before the snakes move
+	if a.area.tick() {
+		a.closeIn()
+	}
+
+	// Work out where every living snake's head is going
+	var moving []*arenaPlayer
+	var heads []models.Point
+	for _, p := range a.players {
+		if !p.alive {
+			continue
+		}
+		if len(p.inputs) > 0 {
+			p.direction = p.inputs[0].dir
+			p.ack = max(p.ack, p.inputs[0].seq)
+			p.inputs = p.inputs[1:]
+		}
+
+		head := nextPosition(p.body[0], p.direction)
+		if a.config.WrapAround {
+			head = wrapPoint(head, a.area.bounds)
+		}
+		moving = append(moving, p)
+		heads = append(heads, head)
+	}
+
+	// Bodies as they stand before anyone moves, with the snakes on each cell
+	bodies := make(map[string][]*arenaPlayer)
+	for _, p := range moving {
+		for _, part := range p.body {
+			key := pointKey(part)
+			bodies[key] = append(bodies[key], p)
+		}
+	}
+
+	crashed := make([]bool, len(moving))
+	for i, head := range heads {
+		p := moving[i]
+		key := pointKey(head)
+		if !a.inBounds(head) || a.obstacles[key] {
+			crashed[i] = true // Wall or obstacle
+			continue
+		}
+		for _, other := range bodies[key] {
+			if !a.harmless(p, other) {
+				crashed[i] = true // Head-to-body, including its own body
+			}
+		}
+		for j, other := range moving {
+			if j == i || a.harmless(p, other) {
+				continue
+			}
+			if heads[j] == head {
+				crashed[i] = true // Head-to-head on the same cell
+			}
+			if head == other.body[0] && heads[j] == p.body[0] {
+				crashed[i] = true // Heads swapped places
+			}
+		}
+	}
+
+	// Move the survivors and let them eat
+	for i, p := range moving {
+		if crashed[i] {
+			a.kill(p)
+			continue
+		}
+
+		p.body = append([]models.Point{heads[i]}, p.body...)
+		if remaining, item, ok := takeFood(a.food, heads[i]); ok {
+			a.food = remaining
+			p.score += item.Score
+			p.growth += item.Growth
+		}
+		p.body, p.growth = grow(p.body, p.growth)
+	}
+
+	a.food = ageFood(a.food)
+	a.generateFood()
+
+	a.checkFinished()
+}
+
+// kill removes a crashed snake from the board
+// Callers must hold the mutex
+func (a *Arena) kill(p *arenaPlayer) {
+	p.alive = false
+	p.body = nil
+	p.inputs = nil
+}
+
+// checkFinished ends the arena once it has been decided
+// Normally that is when every snake has died and the top scorer wins. On a
+// battle-royale board it is as soon as one snake, or one team, is left alive,
+// and the survivors win
+// Callers must hold the mutex
+func (a *Arena) checkFinished() {
+	if len(a.players) == 0 {
+		return
+	}
+
+	var survivors []*arenaPlayer
+	sides := make(map[string]bool)
+	for _, p := range a.players {
+		if !p.alive {
+			continue
+		}
+		survivors = append(survivors, p)
+		if p.team != "" {
+			sides[p.team] = true
+		} else {
+			sides[p.owner] = true
+		}
+	}
+
+	royale := a.config.ShrinkEvery > 0 && len(a.players) > 1
+	switch {
+	case len(survivors) == 0:
+		a.status = models.RoomFinished
+		a.winner = topScorer(a.players)
+		a.winningTeam = a.topTeam()
+	case royale && len(sides) == 1:
+		a.status = models.RoomFinished
+		a.winner = topScorer(survivors)
+		a.winningTeam = survivors[0].team
+	}
+}
+
+// topScorer returns the owner with the highest score among players
+// Ties go to the player who joined first
+func topScorer(players []*arenaPlayer) string {
+	var best *arenaPlayer
+	for _, p := range players {
+		if best == nil || p.score > best.score {
+			best = p
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.owner
+}
+
+// inBounds reports whether p lies inside the current playable area
+func (a *Arena) inBounds(p models.Point) bool {
+	return a.area.bounds.Contains(p)
+}
+
+// occupied returns every cell taken by a snake or food, keyed by pointKey
+// Callers must hold the mutex
+func (a *Arena) occupied() map[string]bool {
+	occupied := make(map[string]bool)
+	for _, p := range a.players {
+		for _, part := range p.body {
+			occupied[pointKey(part)] = true
+		}
+	}
+	for _, item := range a.food {
+		occupied[pointKey(item.Point)] = true
+	}
+	return occupied
+}
+
+// generateFood tops the board up to the configured number of food items
+// When no free cell is left the board keeps fewer items
+// Callers must hold the mutex
+func (a *Arena) generateFood() {
+	for len(a.food) < a.config.FoodItems {
+		occupied := a.occupied()
+		for key := range a.obstacles {
+			occupied[key] = true
+		}
+		cell, ok := randomFreeCell(a.rng, a.area.bounds, occupied)
+		if !ok {
+			return
+		}
+		a.food = append(a.food, newFoodItem(rollFoodType(a.rng), cell))
+	}
+}
+
+// TickInterval returns how long to wait between arena updates
+// Every snake in the arena shares this tick
+func (a *Arena) TickInterval() time.Duration {
+	return time.Duration(a.config.Speed) * time.Millisecond
+}
+
+// GetState returns the shared arena state
+// Every snake is listed with its owner, sorted by owner for stable output
+func (a *Arena) GetState() models.GameState {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	snakes := make([]models.SnakeState, 0, len(a.players))
+	for _, p := range a.players {
+		snakes = append(snakes, models.SnakeState{
+			Owner:     p.owner,
+			Body:      p.body,
+			Direction: p.direction,
+			Score:     p.score,
+			Alive:     p.alive,
+			Team:      p.team,
+			Ack:       p.ack,
+		})
+	}
+	sort.Slice(snakes, func(i, j int) bool { return snakes[i].Owner < snakes[j].Owner })
+
+	return models.GameState{
+		ID:          a.id,
+		Food:        a.food,
+		GameOver:    a.status == models.RoomFinished,
+		Obstacles:   a.walls,
+		Width:       a.config.Width,
+		Height:      a.config.Height,
+		Seed:        a.seed,
+		Speed:       a.config.Speed,
+		Tick:        a.tick,
+		Snakes:      snakes,
+		Winner:      a.winner,
+		Status:      a.status,
+		Countdown:   (a.countdown*a.config.Speed + 999) / 1000,
+		Shrink:      a.area.state(),
+		Teams:       a.teamStates(),
+		WinningTeam: a.winningTeam,
+	}
+}
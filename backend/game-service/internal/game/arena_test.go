@@ -0,0 +1,199 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// newTestArena creates an arena with two snakes placed by the test
+func newTestArena(t *testing.T, a, b []models.Point, dirA, dirB models.Direction) *Arena {
+	t.Helper()
+	arena := NewArena(models.GameConfig{GridSize: 20, Seed: 1})
+	for _, owner := range []string{"alice", "bob"} {
+		if err := arena.Join(owner); err != nil {
+			t.Fatalf("Join %s: %v", owner, err)
+		}
+	}
+	arena.players[0].body, arena.players[0].direction = a, dirA
+	arena.players[1].body, arena.players[1].direction = b, dirB
+	arena.food = nil
+	return arena
+}
+
+func TestArenaJoin(t *testing.T) {
+	arena := NewArena(models.GameConfig{GridSize: 20, Seed: 1})
+	for _, owner := range []string{"alice", "bob", "carol"} {
+		if err := arena.Join(owner); err != nil {
+			t.Fatalf("Join %s: %v", owner, err)
+		}
+	}
+	if err := arena.Join("alice"); err == nil {
+		t.Error("Expected error when joining twice with the same name")
+	}
+
+	state := arena.GetState()
+	if len(state.Snakes) != 3 {
+		t.Fatalf("Expected 3 snakes in state, got %d", len(state.Snakes))
+	}
+	seen := make(map[string]bool)
+	for _, snake := range state.Snakes {
+		key := pointKey(snake.Body[0])
+		if seen[key] {
+			t.Errorf("Two snakes spawned on (%d,%d)", snake.Body[0].X, snake.Body[0].Y)
+		}
+		seen[key] = true
+	}
+
+	// The arena is running, so bob stays in the standings
+	arena.Leave("bob")
+	if state := arena.GetState(); len(state.Snakes) != 3 || state.Snakes[1].Alive {
+		t.Errorf("Expected bob to stay listed as dead after leaving, got %+v", state.Snakes)
+	}
+}
+
+func TestArenaHeadToHead(t *testing.T) {
+	// Both heads move onto (10,5)
+	arena := newTestArena(t,
+		[]models.Point{{X: 9, Y: 5}}, []models.Point{{X: 11, Y: 5}},
+		models.Right, models.Left)
+	arena.Update()
+
+	for _, p := range arena.players {
+		if p.alive {
+			t.Errorf("Expected %s to die in a head-on collision", p.owner)
+		}
+	}
+	if !arena.GetState().GameOver {
+		t.Error("Expected game over once every snake has died")
+	}
+}
+
+func TestArenaHeadSwap(t *testing.T) {
+	// Adjacent heads moving into each other swap cells
+	arena := newTestArena(t,
+		[]models.Point{{X: 9, Y: 5}}, []models.Point{{X: 10, Y: 5}},
+		models.Right, models.Left)
+	arena.Update()
+
+	for _, p := range arena.players {
+		if p.alive {
+			t.Errorf("Expected %s to die when heads swap places", p.owner)
+		}
+	}
+}
+
+func TestArenaHeadToBody(t *testing.T) {
+	// Alice runs into Bob's body while Bob moves away safely
+	arena := newTestArena(t,
+		[]models.Point{{X: 4, Y: 5}},
+		[]models.Point{{X: 5, Y: 4}, {X: 5, Y: 5}, {X: 5, Y: 6}},
+		models.Right, models.Up)
+	arena.Update()
+
+	if arena.players[0].alive {
+		t.Error("Expected alice to die running into bob's body")
+	}
+	if !arena.players[1].alive {
+		t.Error("Expected bob to survive")
+	}
+	if arena.GetState().GameOver {
+		t.Error("Expected the arena to keep running while bob is alive")
+	}
+}
+
+func TestArenaEatAndWinner(t *testing.T) {
+	arena := newTestArena(t,
+		[]models.Point{{X: 2, Y: 2}}, []models.Point{{X: 0, Y: 10}},
+		models.Right, models.Left)
+	arena.food = []models.FoodItem{newFoodItem(models.FoodBonus, models.Point{X: 3, Y: 2})}
+
+	arena.Update()
+	alice := arena.players[0]
+	if alice.score != foodSpecs[models.FoodBonus].score {
+		t.Errorf("Expected alice to score %d, got %d", foodSpecs[models.FoodBonus].score, alice.score)
+	}
+	if arena.players[1].alive {
+		t.Error("Expected bob to hit the left wall")
+	}
+
+	// Alice eventually hits the right wall and wins on score
+	for i := 0; i < 20 && !arena.GetState().GameOver; i++ {
+		arena.Update()
+	}
+	state := arena.GetState()
+	if !state.GameOver {
+		t.Fatal("Expected game over")
+	}
+	if state.Winner != "alice" {
+		t.Errorf("Expected alice to win, got %q", state.Winner)
+	}
+	if err := arena.Join("carol"); err == nil {
+		t.Error("Expected error when joining a finished arena")
+	}
+}
+
+func TestArenaLifecycle(t *testing.T) {
+	// Two players needed and a one second countdown at 200ms per tick
+	arena := NewArena(models.GameConfig{GridSize: 20, Speed: 200, Seed: 1, MinPlayers: 2, MaxPlayers: 2, Countdown: 1})
+	arena.Join("alice")
+	if state := arena.GetState(); state.Status != models.RoomWaiting {
+		t.Fatalf("Expected waiting with one player, got %s", state.Status)
+	}
+
+	// Nobody moves while waiting
+	head := arena.players[0].body[0]
+	arena.Update()
+	if arena.players[0].body[0] != head {
+		t.Error("Expected snakes to stay still while waiting")
+	}
+
+	arena.Join("bob")
+	state := arena.GetState()
+	if state.Status != models.RoomCountdown || state.Countdown != 1 {
+		t.Fatalf("Expected a 1s countdown, got %s with %d", state.Status, state.Countdown)
+	}
+	if err := arena.Join("carol"); err == nil {
+		t.Error("Expected error when joining a full arena")
+	}
+
+	// Leaving during the countdown goes back to waiting
+	arena.Leave("bob")
+	if state := arena.GetState(); state.Status != models.RoomWaiting {
+		t.Errorf("Expected waiting after a player left, got %s", state.Status)
+	}
+	arena.Join("bob")
+
+	for i := 0; i < 5; i++ {
+		arena.Update()
+	}
+	if state := arena.GetState(); state.Status != models.RoomRunning {
+		t.Fatalf("Expected running after 5 ticks, got %s", state.Status)
+	}
+	arena.Update()
+	if arena.players[0].body[0] == head {
+		t.Error("Expected snakes to move once running")
+	}
+}
+
+func TestArenaInputAck(t *testing.T) {
+	arena := newTestArena(t,
+		[]models.Point{{X: 5, Y: 5}}, []models.Point{{X: 5, Y: 15}},
+		models.Right, models.Right)
+	arena.status = models.RoomRunning
+
+	arena.SetDirection("alice", models.Up, 7)
+	arena.SetDirection("bob", models.Left, 3) // Reversal, dropped straight away
+	arena.Update()
+
+	state := arena.GetState()
+	if state.Tick != 1 {
+		t.Errorf("Expected tick 1, got %d", state.Tick)
+	}
+	for _, snake := range state.Snakes {
+		want := map[string]uint64{"alice": 7, "bob": 3}[snake.Owner]
+		if snake.Ack != want {
+			t.Errorf("Expected %s's ack to be %d, got %d", snake.Owner, want, snake.Ack)
+		}
+	}
+}
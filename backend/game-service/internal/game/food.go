@@ -0,0 +1,218 @@
+package game
+
+import (
+	"math/rand"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// foodSpec describes the effect and lifetime of a food type
+type foodSpec struct {
+	score    int // Points awarded when eaten
+	growth   int // Segments added when eaten; negative values shrink the snake
+	lifetime int // Ticks before the item expires; zero never expires
+	weight   int // Relative chance of spawning compared to the other types
+}
+
+// foodSpecs defines how every food type behaves
+var foodSpecs = map[models.FoodType]foodSpec{
+	models.FoodNormal: {score: 1, growth: 1, lifetime: 0, weight: 80},
+	models.FoodBonus:  {score: 5, growth: 2, lifetime: 30, weight: 12},
+	models.FoodShrink: {score: 2, growth: -2, lifetime: 40, weight: 8},
+}
+
+// foodTypes lists the food types in a fixed order
+// Rolling over a slice instead of the map keeps seeded games reproducible
+var foodTypes = []models.FoodType{models.FoodNormal, models.FoodBonus, models.FoodShrink}
+
+// defaultFoodItems is the number of food items on the board when the config does not set one
+const defaultFoodItems = 1
+
+// maxFoodShare is the largest fraction of the board, as 1/maxFoodShare, that food may cover
+const maxFoodShare = 4
+
+// randomAttempts is how many random cells randomFreeCell tries before
+// listing the free ones
+const randomAttempts = 64
+
+// generateFood tops the board up to the configured number of food items
+// New items never appear on the snake's body, an obstacle or another item;
+// when no free cell is left the board keeps fewer items
+// Callers must hold the mutex
+func (g *Game) generateFood() {
+	for len(g.state.Food) < g.config.FoodItems {
+		item, ok := g.newFood()
+		if !ok {
+			return
+		}
+		g.state.Food = append(g.state.Food, item)
+	}
+}
+
+// newFood rolls a food type and places it on a free cell
+// Returns false if the board has no free cell
+func (g *Game) newFood() (models.FoodItem, bool) {
+	foodType := rollFoodType(g.rng)
+	cell, ok := g.freeCell()
+	if !ok {
+		return models.FoodItem{}, false
+	}
+	return newFoodItem(foodType, cell), true
+}
+
+// newFoodItem creates a food item of the given type at p
+func newFoodItem(foodType models.FoodType, p models.Point) models.FoodItem {
+	spec := foodSpecs[foodType]
+	return models.FoodItem{
+		Point:     p,
+		Type:      foodType,
+		Score:     spec.score,
+		Growth:    spec.growth,
+		TicksLeft: spec.lifetime,
+	}
+}
+
+// rollFoodType picks a food type at random, weighted by foodSpec.weight
+func rollFoodType(rng *rand.Rand) models.FoodType {
+	total := 0
+	for _, t := range foodTypes {
+		total += foodSpecs[t].weight
+	}
+
+	roll := rng.Intn(total)
+	for _, t := range foodTypes {
+		roll -= foodSpecs[t].weight
+		if roll < 0 {
+			return t
+		}
+	}
+	return models.FoodNormal
+}
+
+// freeCell returns a random cell not taken by the snake, an obstacle, food or a power-up
+// Returns false if every cell is taken
+func (g *Game) freeCell() (models.Point, bool) {
+	// Create a map of occupied positions for O(1) lookup
+	occupied := make(map[string]bool, len(g.state.Snake)+len(g.obstacles)+len(g.state.Food)+len(g.state.PowerUps))
+	for _, p := range g.state.Snake {
+		occupied[pointKey(p)] = true
+	}
+	for key := range g.obstacles {
+		occupied[key] = true
+	}
+	for _, item := range g.state.Food {
+		occupied[pointKey(item.Point)] = true
+	}
+	for _, powerUp := range g.state.PowerUps {
+		occupied[pointKey(powerUp.Point)] = true
+	}
+	return randomFreeCell(g.rng, g.area.bounds, occupied)
+}
+
+// randomFreeCell picks a random cell inside r that is not in occupied,
+// which is keyed by pointKey
+// Random cells are tried first; on a crowded board one of the remaining free
+// cells is picked instead. Returns false if r has no free cell
+func randomFreeCell(rng *rand.Rand, r models.Rect, occupied map[string]bool) (models.Point, bool) {
+	if r.Width <= 0 || r.Height <= 0 {
+		return models.Point{}, false
+	}
+	for i := 0; i < randomAttempts; i++ {
+		p := models.Point{X: r.X + rng.Intn(r.Width), Y: r.Y + rng.Intn(r.Height)}
+		if !occupied[pointKey(p)] {
+			return p, true
+		}
+	}
+
+	var free []models.Point
+	for y := r.Y; y < r.Y+r.Height; y++ {
+		for x := r.X; x < r.X+r.Width; x++ {
+			if p := (models.Point{X: x, Y: y}); !occupied[pointKey(p)] {
+				free = append(free, p)
+			}
+		}
+	}
+	if len(free) == 0 {
+		return models.Point{}, false
+	}
+	return free[rng.Intn(len(free))], true
+}
+
+// eatFood applies the effect of any food item at the given point
+// The item is removed from the board and its score and growth are applied
+// Returns true if something was eaten
+func (g *Game) eatFood(p models.Point) bool {
+	remaining, item, ok := takeFood(g.state.Food, p)
+	if !ok {
+		return false
+	}
+
+	g.state.Food = remaining
+	g.state.Score += item.Score
+	g.growth += item.Growth
+	return true
+}
+
+// takeFood removes the food item at p, if there is one
+// It returns a new slice so states already handed out by GetState are untouched
+func takeFood(food []models.FoodItem, p models.Point) ([]models.FoodItem, models.FoodItem, bool) {
+	for i, item := range food {
+		if item.Point != p {
+			continue
+		}
+
+		remaining := make([]models.FoodItem, 0, len(food))
+		remaining = append(remaining, food[:i]...)
+		return append(remaining, food[i+1:]...), item, true
+	}
+	return food, models.FoodItem{}, false
+}
+
+// expireFood counts down the lifetime of every food item and drops expired ones
+// Expired items are replaced on the next call to generateFood
+func (g *Game) expireFood() {
+	g.state.Food = ageFood(g.state.Food)
+}
+
+// ageFood returns a new slice with every item's lifetime counted down by one
+// tick and expired items left out
+func ageFood(food []models.FoodItem) []models.FoodItem {
+	remaining := make([]models.FoodItem, 0, len(food))
+	for _, item := range food {
+		if item.TicksLeft > 0 {
+			item.TicksLeft--
+			if item.TicksLeft == 0 {
+				continue // Lifetime is over
+			}
+		}
+		remaining = append(remaining, item)
+	}
+	return remaining
+}
+
+// applyGrowth trims the tail after the snake has moved
+// Pending growth keeps the tail in place one tick at a time, while
+// negative growth removes extra segments down to a single head
+func (g *Game) applyGrowth() {
+	g.state.Snake, g.growth = grow(g.state.Snake, g.growth)
+}
+
+// grow trims the tail of a body that has just moved and returns the
+// remaining growth; see applyGrowth
+func grow(body []models.Point, growth int) ([]models.Point, int) {
+	if growth > 0 {
+		return body, growth - 1
+	}
+
+	// Remove tail if nothing is pending (snake doesn't grow)
+	body = body[:len(body)-1]
+
+	for growth < 0 && len(body) > 1 {
+		body = body[:len(body)-1]
+		growth++
+	}
+	if len(body) == 1 {
+		growth = 0 // Nothing left to shrink
+	}
+	return body, growth
+}
@@ -0,0 +1,143 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// placeFood puts a single food item of the given type in front of the snake
+func placeFood(g *Game, p models.Point, foodType models.FoodType) {
+	spec := foodSpecs[foodType]
+	g.state.Food = []models.FoodItem{{
+		Point:     p,
+		Type:      foodType,
+		Score:     spec.score,
+		Growth:    spec.growth,
+		TicksLeft: spec.lifetime,
+	}}
+}
+
+func TestEatNormalFood(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, InitialX: 5, InitialY: 5, Seed: 1})
+	placeFood(game, models.Point{X: 6, Y: 5}, models.FoodNormal)
+
+	game.Update()
+
+	if game.state.Score != 1 {
+		t.Errorf("Expected score 1, got %d", game.state.Score)
+	}
+	if len(game.state.Snake) != 2 {
+		t.Errorf("Expected snake length 2, got %d", len(game.state.Snake))
+	}
+	if len(game.state.Food) != 1 {
+		t.Errorf("Expected eaten food to respawn, got %d items", len(game.state.Food))
+	}
+}
+
+func TestEatBonusFood(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, InitialX: 5, InitialY: 5, Seed: 1})
+	placeFood(game, models.Point{X: 6, Y: 5}, models.FoodBonus)
+
+	game.Update()
+	game.state.Food = nil // Keep the next tick free of food
+	game.Update()
+
+	spec := foodSpecs[models.FoodBonus]
+	if game.state.Score != spec.score {
+		t.Errorf("Expected score %d, got %d", spec.score, game.state.Score)
+	}
+	if len(game.state.Snake) != 1+spec.growth {
+		t.Errorf("Expected snake length %d, got %d", 1+spec.growth, len(game.state.Snake))
+	}
+}
+
+func TestEatShrinkFood(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, Seed: 1})
+	game.state.Snake = []models.Point{{X: 5, Y: 5}, {X: 4, Y: 5}, {X: 3, Y: 5}, {X: 2, Y: 5}, {X: 1, Y: 5}}
+	placeFood(game, models.Point{X: 6, Y: 5}, models.FoodShrink)
+
+	game.Update()
+
+	if len(game.state.Snake) != 3 {
+		t.Errorf("Expected snake length 3 after shrink, got %d", len(game.state.Snake))
+	}
+	if game.state.Snake[0] != (models.Point{X: 6, Y: 5}) {
+		t.Errorf("Expected head to keep moving, got %v", game.state.Snake[0])
+	}
+
+	// Shrinking never removes the head
+	game.state.Snake = game.state.Snake[:1]
+	placeFood(game, models.Point{X: 7, Y: 5}, models.FoodShrink)
+	game.Update()
+	if len(game.state.Snake) != 1 {
+		t.Errorf("Expected snake to keep its head, got length %d", len(game.state.Snake))
+	}
+}
+
+func TestFoodExpires(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, InitialX: 0, InitialY: 0, Seed: 1})
+	game.state.Direction = models.Down
+	placeFood(game, models.Point{X: 19, Y: 19}, models.FoodBonus)
+	game.state.Food[0].TicksLeft = 2
+
+	game.Update()
+	if len(game.state.Food) != 1 || game.state.Food[0].TicksLeft != 1 {
+		t.Fatalf("Expected bonus food with 1 tick left, got %v", game.state.Food)
+	}
+
+	game.Update()
+	if len(game.state.Food) != 1 {
+		t.Fatalf("Expected expired food to be replaced, got %d items", len(game.state.Food))
+	}
+	if f := game.state.Food[0]; f.TicksLeft != foodSpecs[f.Type].lifetime {
+		t.Errorf("Expected a freshly spawned item, got %v", f)
+	}
+}
+
+func TestFoodItemsConfig(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, FoodItems: 5, Seed: 7})
+	if len(game.state.Food) != 5 {
+		t.Fatalf("Expected 5 food items, got %d", len(game.state.Food))
+	}
+
+	seen := make(map[models.Point]bool)
+	for _, item := range game.state.Food {
+		if seen[item.Point] {
+			t.Errorf("Two food items share cell (%d,%d)", item.X, item.Y)
+		}
+		seen[item.Point] = true
+	}
+}
+
+func TestFoodItemsCapped(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 4, FoodItems: 1000, Seed: 7})
+	if game.config.FoodItems != 4 || len(game.state.Food) != 4 {
+		t.Errorf("Expected food capped at a quarter of a 4x4 board, got %d configured and %d placed",
+			game.config.FoodItems, len(game.state.Food))
+	}
+}
+
+func TestFullBoardSkipsFood(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 3, InitialX: 0, InitialY: 0, Seed: 7})
+
+	// Fill every cell with the snake
+	game.state.Snake = nil
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			game.state.Snake = append(game.state.Snake, models.Point{X: x, Y: y})
+		}
+	}
+	game.state.Food = nil
+	game.generateFood()
+	if len(game.state.Food) != 0 {
+		t.Errorf("Expected no food on a full board, got %v", game.state.Food)
+	}
+
+	// The last free cell is found even when random tries keep missing it
+	game.state.Snake = game.state.Snake[:8]
+	game.generateFood()
+	if len(game.state.Food) != 1 || game.state.Food[0].Point != (models.Point{X: 2, Y: 2}) {
+		t.Errorf("Expected food on the last free cell, got %v", game.state.Food)
+	}
+}
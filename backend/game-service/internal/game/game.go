@@ -1,8 +1,13 @@
 package game
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
+	"log"
 	"math/rand"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/snake-game/game-service/pkg/models"
 )
@@ -10,56 +15,157 @@ import (
 // Game represents the snake game instance
 // It maintains the game state and provides thread-safe access to it
 type Game struct {
-	state    models.GameState  // Current state of the game (snake position, food, score, etc.)
-	config   models.GameConfig // Game configuration parameters
-	mutex    sync.RWMutex      // Mutex to ensure thread-safe access to game state
-	gameOver bool              // Local cache of game over state for quick access
+	state     models.GameState  // Current state of the game (snake position, food, score, etc.)
+	config    models.GameConfig // Game configuration parameters
+	mutex     sync.RWMutex      // Mutex to ensure thread-safe access to game state
+	gameOver  bool              // Local cache of game over state for quick access
+	obstacles map[string]bool   // Wall cells from the selected map, keyed by pointKey
+	rng       *rand.Rand        // Per-game random source so a seed replays the same game
+	growth    int               // Segments still to be added (positive) or removed (negative)
+	inputs    []input           // Turns waiting to be applied, one per tick
+	area      shrinker          // Playable area, which closes in on battle-royale boards
+}
+
+// maxQueuedInputs bounds how many turns can wait for upcoming ticks
+// Presses beyond this are dropped so a held key cannot build up lag
+const maxQueuedInputs = 3
+
+// input is a queued turn together with the client's sequence number for it
+// Clients that do not number their inputs send zero
+type input struct {
+	dir models.Direction
+	seq uint64 // Acknowledged once the turn is applied; covers any dropped turns sent after it
 }
 
 // NewGame creates a new game instance with the given configuration
 // It initializes the snake at the specified starting position and generates the first food
 func NewGame(config models.GameConfig) *Game {
+	config = normalizeConfig(config)
+	seed := newSeed(config)
 	game := &Game{
 		config: config,
 		state: models.GameState{
+			ID:        newGameID(),                                              // Unique per game, changes on reset
 			Snake:     []models.Point{{X: config.InitialX, Y: config.InitialY}}, // Start with single segment
 			Direction: models.Right,                                             // Snake starts moving right by default
 			Score:     0,                                                        // Initial score is 0
 			GameOver:  false,                                                    // Game starts in active state
+			Width:     config.Width,                                             // Board dimensions for the client
+			Height:    config.Height,
+			Seed:      seed,
 		},
+		rng:  rand.New(rand.NewSource(seed)),
+		area: newShrinker(config),
 	}
+	game.state.Shrink = game.area.state()
+	game.loadMap()      // Place the map's walls before anything else
 	game.generateFood() // Place first food item
+	game.updateSpeed()  // Start at level 1 with the configured speed
 	return game
 }
 
-// generateFood creates new food at a random position
-// It ensures food doesn't appear on the snake's body
-func (g *Game) generateFood() {
-	g.mutex.Lock()
-	defer g.mutex.Unlock()
+// newGameID returns a random identifier for a game
+// It comes from crypto/rand so seeded games still get distinct IDs
+func newGameID() string {
+	b := make([]byte, 8)
+	if _, err := crand.Read(b); err != nil {
+		log.Printf("Error generating game ID: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// defaultSpeed is the tick interval in milliseconds when the config does not set one
+const defaultSpeed = 200
+
+// Board size limits
+const (
+	defaultBoardSize = 20  // Width and height when neither they nor GridSize are set
+	maxBoardSize     = 200 // Largest width and height a board can have
+)
 
-	// Create a map of occupied positions for O(1) lookup
-	occupied := make(map[string]bool)
-	for _, p := range g.state.Snake {
-		occupied[pointKey(p)] = true
+// normalizeConfig fills in the board dimensions and starting position
+// Width and Height fall back to GridSize and then to defaultBoardSize, and are
+// capped at maxBoardSize. A start outside the board is moved to the centre so
+// rectangular boards always have a valid start, and food is capped at a
+// quarter of the board
+func normalizeConfig(config models.GameConfig) models.GameConfig {
+	config.Width = boardSize(config.Width, config.GridSize)
+	config.Height = boardSize(config.Height, config.GridSize)
+	if config.InitialX < 0 || config.InitialX >= config.Width {
+		config.InitialX = config.Width / 2
+	}
+	if config.InitialY < 0 || config.InitialY >= config.Height {
+		config.InitialY = config.Height / 2
+	}
+	if config.FoodItems <= 0 {
+		config.FoodItems = defaultFoodItems
 	}
+	config.FoodItems = min(config.FoodItems, max(1, config.Width*config.Height/maxFoodShare))
+	if config.Speed <= 0 {
+		config.Speed = defaultSpeed
+	}
+	return normalizeLevels(config)
+}
 
-	// Keep trying random positions until we find an unoccupied one
-	for {
-		x := rand.Intn(g.config.GridSize)
-		y := rand.Intn(g.config.GridSize)
-		key := pointKey(models.Point{X: x, Y: y})
-		if !occupied[key] {
-			g.state.Food = models.Point{X: x, Y: y}
-			break
-		}
+// boardSize returns a positive board dimension no larger than maxBoardSize
+// A size that is not set falls back to gridSize and then to defaultBoardSize
+func boardSize(size, gridSize int) int {
+	if size <= 0 {
+		size = gridSize
+	}
+	if size <= 0 {
+		size = defaultBoardSize
+	}
+	return min(size, maxBoardSize)
+}
+
+// newSeed returns the seed for a new game
+// A seed set in the config is reused so every game with it is identical;
+// otherwise a fresh non-zero seed no larger than models.MaxSeed is picked
+func newSeed(config models.GameConfig) int64 {
+	if config.Seed != 0 {
+		return config.Seed
+	}
+	return max(time.Now().UnixNano()&models.MaxSeed, 1)
+}
+
+// loadMap places the obstacles of the configured map on the board
+// A map with a wall on the starting cell is logged and the game falls back
+// to an empty board
+func (g *Game) loadMap() {
+	obstacles, set := loadObstacles(g.config)
+	if start := (models.Point{X: g.config.InitialX, Y: g.config.InitialY}); set[pointKey(start)] {
+		log.Printf("Error loading map %q: wall on the starting cell (%d,%d)", g.config.Map, start.X, start.Y)
+		obstacles, set = nil, make(map[string]bool)
+	}
+	g.state.Obstacles, g.obstacles = obstacles, set
+}
+
+// loadObstacles returns the obstacles of the configured map as a list for
+// clients and as a set keyed by pointKey for collision checks
+// An unknown map is logged and the game falls back to an empty board
+func loadObstacles(config models.GameConfig) ([]models.Point, map[string]bool) {
+	set := make(map[string]bool)
+	if config.Map == "" {
+		return nil, set
+	}
+
+	m, err := LoadMap(config.Map, config.MapDir, config.Width, config.Height)
+	if err != nil {
+		log.Printf("Error loading map %q: %v", config.Map, err)
+		return nil, set
+	}
+
+	for _, p := range m.Obstacles {
+		set[pointKey(p)] = true
 	}
+	return m.Obstacles, set
 }
 
 // pointKey generates a unique string key for a point
 // Used for efficient collision detection using a hash map
 func pointKey(p models.Point) string {
-	return string(p.X) + "," + string(p.Y)
+	return strconv.Itoa(p.X) + "," + strconv.Itoa(p.Y)
 }
 
 // Update updates the game state based on the current direction
@@ -68,23 +174,33 @@ func (g *Game) Update() {
 	g.mutex.Lock()
 	defer g.mutex.Unlock()
 
+	if g.state.GameOver || g.state.Paused {
+		return // No updates after game over or while paused
+	}
+	g.state.Tick++
+
+	// A shrinking board closes in before the snake moves
+	if g.area.tick() {
+		g.closeIn()
+	}
+	g.state.Shrink = g.area.state()
 	if g.state.GameOver {
-		return // No updates after game over
+		return // Caught outside the closing walls
+	}
+
+	// Apply the next queued turn, one per tick
+	if len(g.inputs) > 0 {
+		g.state.Direction = g.inputs[0].dir
+		g.state.Ack = max(g.state.Ack, g.inputs[0].seq)
+		g.inputs = g.inputs[1:]
 	}
 
 	// Calculate new head position based on current direction
-	head := g.state.Snake[0]
-	var newHead models.Point
+	newHead := nextPosition(g.state.Snake[0], g.state.Direction)
 
-	switch g.state.Direction {
-	case models.Up:
-		newHead = models.Point{X: head.X, Y: head.Y - 1}
-	case models.Down:
-		newHead = models.Point{X: head.X, Y: head.Y + 1}
-	case models.Left:
-		newHead = models.Point{X: head.X - 1, Y: head.Y}
-	case models.Right:
-		newHead = models.Point{X: head.X + 1, Y: head.Y}
+	// In wrap-around mode leaving one edge brings the snake back on the opposite edge
+	if g.config.WrapAround {
+		newHead = g.wrapPoint(newHead)
 	}
 
 	// Check for collisions with walls or self
@@ -96,25 +212,70 @@ func (g *Game) Update() {
 	// Move snake by adding new head
 	g.state.Snake = append([]models.Point{newHead}, g.state.Snake...)
 
-	// Check if food is eaten
-	if newHead == g.state.Food {
-		g.state.Score++  // Increment score
-		g.generateFood() // Generate new food
-	} else {
-		// Remove tail if food wasn't eaten (snake doesn't grow)
-		g.state.Snake = g.state.Snake[:len(g.state.Snake)-1]
+	// Apply the effect of whatever food or power-up the head landed on
+	g.eatFood(newHead)
+	g.collectPowerUp(newHead)
+	g.applyGrowth()
+
+	// The magnet drags nearby food toward the new head
+	g.pullFood(newHead)
+
+	// Age the remaining food and replace anything eaten or expired
+	g.expireFood()
+	g.generateFood()
+	g.tickPowerUps()
+
+	// Passing a score threshold moves to the next, faster level
+	g.updateSpeed()
+}
+
+// nextPosition returns the cell one step from p in the given direction
+func nextPosition(p models.Point, dir models.Direction) models.Point {
+	switch dir {
+	case models.Up:
+		return models.Point{X: p.X, Y: p.Y - 1}
+	case models.Down:
+		return models.Point{X: p.X, Y: p.Y + 1}
+	case models.Left:
+		return models.Point{X: p.X - 1, Y: p.Y}
+	case models.Right:
+		return models.Point{X: p.X + 1, Y: p.Y}
+	default:
+		return p
 	}
 }
 
-// checkCollision checks if the given point collides with walls or snake body
+// wrapPoint maps a point that left the playable area back onto the opposite edge
+// Used by the wrap-around (toroidal) board mode
+func (g *Game) wrapPoint(p models.Point) models.Point {
+	return wrapPoint(p, g.area.bounds)
+}
+
+// wrapPoint maps p into r, re-entering on the opposite edge
+func wrapPoint(p models.Point, r models.Rect) models.Point {
+	p.X = r.X + ((p.X-r.X)%r.Width+r.Width)%r.Width
+	p.Y = r.Y + ((p.Y-r.Y)%r.Height+r.Height)%r.Height
+	return p
+}
+
+// checkCollision checks if the given point collides with walls, obstacles or snake body
+// While the ghost effect is active the snake passes through its own body
 // Returns true if collision detected, false otherwise
 func (g *Game) checkCollision(p models.Point) bool {
-	// Check wall collision (grid boundaries)
-	if p.X < 0 || p.X >= g.config.GridSize || p.Y < 0 || p.Y >= g.config.GridSize {
+	// Check wall collision (current bounds of the playable area)
+	if !g.area.bounds.Contains(p) {
+		return true
+	}
+
+	// Check obstacle collision (map walls)
+	if g.obstacles[pointKey(p)] {
 		return true
 	}
 
 	// Check self collision (snake body)
+	if g.hasEffect(models.PowerUpGhost) {
+		return false
+	}
 	for _, part := range g.state.Snake {
 		if p == part {
 			return true
@@ -124,33 +285,102 @@ func (g *Game) checkCollision(p models.Point) bool {
 	return false
 }
 
-// SetDirection sets the snake's direction
-// Prevents 180-degree turns which would cause instant death
-func (g *Game) SetDirection(dir models.Direction) {
+// SetDirection queues a turn for the snake
+// Update applies one queued turn per tick, so quick double turns are kept
+// Prevents 180-degree turns by checking against the last queued direction
+// Turns are ignored while the game is paused. seq is the client's sequence
+// number for the turn, echoed in the state's Ack once the turn is settled
+func (g *Game) SetDirection(dir models.Direction, seq uint64) {
 	g.mutex.Lock()
 	defer g.mutex.Unlock()
 
-	// Prevent 180-degree turns by checking opposite directions
+	var settled uint64
+	if g.state.Paused {
+		g.inputs, settled = dropTurn(g.inputs, seq)
+	} else {
+		g.inputs, settled = queueTurn(g.inputs, g.state.Direction, input{dir: dir, seq: seq})
+	}
+	g.state.Ack = max(g.state.Ack, settled)
+}
+
+// queueTurn appends a turn to a bounded turn queue if it is a valid turn
+// Turns are checked against where the snake will be heading once everything
+// already queued has been applied, not against the current direction.
+// Turns that are not queued are dropped as dropTurn describes
+func queueTurn(inputs []input, current models.Direction, in input) ([]input, uint64) {
+	last := current
+	if len(inputs) > 0 {
+		last = inputs[len(inputs)-1].dir
+	}
+
+	if len(inputs) < maxQueuedInputs && validTurn(last, in.dir) {
+		return append(inputs, in), 0
+	}
+	return dropTurn(inputs, in.seq)
+}
+
+// dropTurn settles the sequence number of a turn that will not be applied
+// It is acknowledged together with the last queued turn, so acknowledgements
+// stay in order, or straight away if nothing is queued; the sequence number
+// to acknowledge straight away is returned, zero if none
+func dropTurn(inputs []input, seq uint64) ([]input, uint64) {
+	if len(inputs) == 0 {
+		return inputs, seq
+	}
+	last := inputs[len(inputs)-1]
+	last.seq = max(last.seq, seq)
+	return append(inputs[:len(inputs)-1:len(inputs)-1], last), 0
+}
+
+// validTurn reports whether a snake heading last can turn to dir
+// 180-degree turns are not allowed, and repeating the current heading would
+// only waste a tick
+func validTurn(last, dir models.Direction) bool {
 	switch dir {
 	case models.Up:
-		if g.state.Direction != models.Down {
-			g.state.Direction = dir
-		}
+		return last != models.Down && last != dir
 	case models.Down:
-		if g.state.Direction != models.Up {
-			g.state.Direction = dir
-		}
+		return last != models.Up && last != dir
 	case models.Left:
-		if g.state.Direction != models.Right {
-			g.state.Direction = dir
-		}
+		return last != models.Right && last != dir
 	case models.Right:
-		if g.state.Direction != models.Left {
-			g.state.Direction = dir
-		}
+		return last != models.Left && last != dir
+	}
+	return false
+}
+
+// Pause freezes the game so Update leaves the state untouched
+// A finished game cannot be paused
+func (g *Game) Pause() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if !g.state.GameOver {
+		g.state.Paused = true
 	}
 }
 
+// Resume continues a paused game from where it stopped
+func (g *Game) Resume() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.state.Paused = false
+}
+
+// Paused reports whether the game is paused
+func (g *Game) Paused() bool {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.state.Paused
+}
+
+// ID returns the current game's unique identifier
+func (g *Game) ID() string {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.state.ID
+}
+
 // GetState returns the current game state
 // Thread-safe read access to game state
 func (g *Game) GetState() models.GameState {
@@ -160,17 +390,30 @@ func (g *Game) GetState() models.GameState {
 }
 
 // Reset resets the game to its initial state
-// Called when starting a new game
+// Called when a player restarts; the game gets a new ID
 func (g *Game) Reset() {
 	g.mutex.Lock()
 	defer g.mutex.Unlock()
 
-	// Reset to initial state
+	// Reset to initial state, restarting the random sequence
+	seed := newSeed(g.config)
+	g.rng = rand.New(rand.NewSource(seed))
+	g.growth = 0
+	g.inputs = nil
+	g.area = newShrinker(g.config) // The full board again
 	g.state = models.GameState{
+		ID:        newGameID(),
 		Snake:     []models.Point{{X: g.config.InitialX, Y: g.config.InitialY}},
 		Direction: models.Right,
 		Score:     0,
 		GameOver:  false,
+		Obstacles: g.state.Obstacles, // The map stays the same between games
+		Width:     g.config.Width,
+		Height:    g.config.Height,
+		Seed:      seed,
+		Shrink:    g.area.state(),
+		Ack:       g.state.Ack, // Inputs keep their numbers across games on the same connection
 	}
 	g.generateFood() // Generate first food for new game
+	g.updateSpeed()  // Back to level 1
 }
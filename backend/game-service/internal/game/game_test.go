@@ -29,8 +29,6 @@ func TestNewGame(t *testing.T) {
 }
 
 func TestSetDirection(t *testing.T) {
-	game := NewGame(models.GameConfig{GridSize: 20})
-
 	// Test valid direction changes
 	tests := []struct {
 		current  models.Direction
@@ -44,8 +42,10 @@ func TestSetDirection(t *testing.T) {
 	}
 
 	for _, test := range tests {
+		game := NewGame(models.GameConfig{GridSize: 20, InitialX: 10, InitialY: 10})
 		game.state.Direction = test.current
-		game.SetDirection(test.new)
+		game.SetDirection(test.new, 0)
+		game.Update() // Turns are queued and applied on the next tick
 		if game.state.Direction != test.expected {
 			t.Errorf("Direction change from %s to %s: expected %s, got %s",
 				test.current, test.new, test.expected, game.state.Direction)
@@ -101,3 +101,258 @@ func TestCollision(t *testing.T) {
 		t.Error("Expected collision with snake body")
 	}
 }
+
+func TestWrapAround(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, WrapAround: true})
+
+	// Leaving the right edge re-enters on the left edge
+	game.state.Snake = []models.Point{{X: 19, Y: 5}}
+	game.state.Food = []models.FoodItem{{Point: models.Point{X: 10, Y: 10}, Type: models.FoodNormal}}
+	game.state.Direction = models.Right
+	game.Update()
+	if game.state.GameOver {
+		t.Fatal("Expected no wall collision in wrap-around mode")
+	}
+	if game.state.Snake[0] != (models.Point{X: 0, Y: 5}) {
+		t.Errorf("Expected head at (0,5), got (%d,%d)", game.state.Snake[0].X, game.state.Snake[0].Y)
+	}
+
+	// Leaving the top edge re-enters at the bottom
+	game.state.Direction = models.Up
+	game.state.Snake = []models.Point{{X: 3, Y: 0}}
+	game.Update()
+	if game.state.Snake[0] != (models.Point{X: 3, Y: 19}) {
+		t.Errorf("Expected head at (3,19), got (%d,%d)", game.state.Snake[0].X, game.state.Snake[0].Y)
+	}
+}
+
+func TestObstacles(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, InitialX: 10, InitialY: 10, Map: "box"})
+
+	if len(game.state.Obstacles) == 0 {
+		t.Fatal("Expected obstacles from the box map in the game state")
+	}
+
+	// Obstacles are deadly
+	if !game.checkCollision(models.Point{X: 0, Y: 5}) {
+		t.Error("Expected collision with box wall")
+	}
+
+	// Food is never placed on an obstacle
+	for i := 0; i < 200; i++ {
+		game.state.Food = nil
+		game.generateFood()
+		if f := game.state.Food[0]; game.obstacles[pointKey(f.Point)] {
+			t.Fatalf("Food placed on obstacle at (%d,%d)", f.X, f.Y)
+		}
+	}
+
+	// The map survives a reset
+	game.Reset()
+	if len(game.state.Obstacles) == 0 {
+		t.Error("Expected obstacles to be kept after reset")
+	}
+}
+
+func TestRectangularBoard(t *testing.T) {
+	game := NewGame(models.GameConfig{Width: 40, Height: 10, InitialX: 10, InitialY: 10})
+
+	// A start outside the board is moved to the centre
+	if head := game.state.Snake[0]; head != (models.Point{X: 10, Y: 5}) {
+		t.Errorf("Expected start at (10,5), got (%d,%d)", head.X, head.Y)
+	}
+	if game.state.Width != 40 || game.state.Height != 10 {
+		t.Errorf("Expected 40x10 board in state, got %dx%d", game.state.Width, game.state.Height)
+	}
+
+	if game.checkCollision(models.Point{X: 39, Y: 9}) {
+		t.Error("Expected (39,9) to be inside a 40x10 board")
+	}
+	if !game.checkCollision(models.Point{X: 5, Y: 10}) {
+		t.Error("Expected collision with bottom wall of a 40x10 board")
+	}
+	if !game.checkCollision(models.Point{X: 40, Y: 5}) {
+		t.Error("Expected collision with right wall of a 40x10 board")
+	}
+
+	for i := 0; i < 200; i++ {
+		game.state.Food = nil
+		game.generateFood()
+		if f := game.state.Food[0]; f.X < 0 || f.X >= 40 || f.Y < 0 || f.Y >= 10 {
+			t.Fatalf("Food placed outside the board at (%d,%d)", f.X, f.Y)
+		}
+	}
+}
+
+func TestBoardSizeLimits(t *testing.T) {
+	// An unset size falls back to a default board instead of an empty one
+	game := NewGame(models.GameConfig{})
+	if game.state.Width != defaultBoardSize || game.state.Height != defaultBoardSize {
+		t.Errorf("Expected a %dx%d board, got %dx%d", defaultBoardSize, defaultBoardSize, game.state.Width, game.state.Height)
+	}
+	game.Update()
+	game.generateFood()
+
+	game = NewGame(models.GameConfig{Width: 1 << 20, Height: -3, GridSize: 1 << 20})
+	if game.state.Width != maxBoardSize || game.state.Height != maxBoardSize {
+		t.Errorf("Expected the board capped at %dx%d, got %dx%d", maxBoardSize, maxBoardSize, game.state.Width, game.state.Height)
+	}
+}
+
+func TestSeededFood(t *testing.T) {
+	config := models.GameConfig{GridSize: 20, InitialX: 10, InitialY: 10, Seed: 42}
+	first := NewGame(config)
+	second := NewGame(config)
+
+	if first.state.Seed != 42 {
+		t.Errorf("Expected seed 42 in state, got %d", first.state.Seed)
+	}
+
+	var sequence []models.FoodItem
+	for i := 0; i < 50; i++ {
+		if first.state.Food[0] != second.state.Food[0] {
+			t.Fatalf("Food %d differs between games with the same seed: %v vs %v",
+				i, first.state.Food[0], second.state.Food[0])
+		}
+		sequence = append(sequence, first.state.Food[0])
+		first.state.Food, second.state.Food = nil, nil
+		first.generateFood()
+		second.generateFood()
+	}
+
+	// Reset replays the same sequence
+	first.Reset()
+	for i, expected := range sequence[:10] {
+		if first.state.Food[0] != expected {
+			t.Fatalf("Food %d after reset: expected %v, got %v", i, expected, first.state.Food[0])
+		}
+		first.state.Food = nil
+		first.generateFood()
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, InitialX: 5, InitialY: 5, Seed: 1})
+	game.Pause()
+
+	if !game.GetState().Paused {
+		t.Fatal("Expected paused flag in state")
+	}
+
+	// Update and turns are frozen while paused
+	game.Update()
+	game.SetDirection(models.Up, 0)
+	if head := game.state.Snake[0]; head != (models.Point{X: 5, Y: 5}) {
+		t.Errorf("Expected snake to stay at (5,5) while paused, got (%d,%d)", head.X, head.Y)
+	}
+	if game.state.Direction != models.Right {
+		t.Errorf("Expected direction to stay RIGHT while paused, got %s", game.state.Direction)
+	}
+
+	game.Resume()
+	game.Update()
+	if game.state.Paused {
+		t.Error("Expected paused flag to be cleared")
+	}
+	if head := game.state.Snake[0]; head != (models.Point{X: 6, Y: 5}) {
+		t.Errorf("Expected snake to move to (6,5) after resume, got (%d,%d)", head.X, head.Y)
+	}
+}
+
+func TestQueuedDoubleTurn(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, InitialX: 10, InitialY: 10, Seed: 1})
+	game.state.Snake = []models.Point{{X: 10, Y: 10}, {X: 9, Y: 10}, {X: 8, Y: 10}}
+	game.state.Food = nil
+
+	// UP then LEFT within one tick while moving RIGHT: both turns are kept
+	game.SetDirection(models.Up, 0)
+	game.SetDirection(models.Left, 0)
+
+	game.Update()
+	if head := game.state.Snake[0]; head != (models.Point{X: 10, Y: 9}) {
+		t.Fatalf("Expected first tick to go up to (10,9), got (%d,%d)", head.X, head.Y)
+	}
+	game.Update()
+	if head := game.state.Snake[0]; head != (models.Point{X: 9, Y: 9}) {
+		t.Fatalf("Expected second tick to go left to (9,9), got (%d,%d)", head.X, head.Y)
+	}
+	if game.state.GameOver {
+		t.Error("Quick double turn should not kill the snake")
+	}
+}
+
+func TestQueuedReversalRejected(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, InitialX: 10, InitialY: 10, Seed: 1})
+
+	// DOWN is checked against the queued UP, not the current RIGHT
+	game.SetDirection(models.Up, 0)
+	game.SetDirection(models.Down, 0)
+	if len(game.inputs) != 1 {
+		t.Errorf("Expected reversal against queued turn to be dropped, got %v", game.inputs)
+	}
+
+	// The queue is bounded
+	game.SetDirection(models.Left, 0)
+	game.SetDirection(models.Down, 0)
+	game.SetDirection(models.Right, 0)
+	if len(game.inputs) != maxQueuedInputs {
+		t.Errorf("Expected queue capped at %d, got %v", maxQueuedInputs, game.inputs)
+	}
+}
+
+func TestInputAck(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, InitialX: 10, InitialY: 10, Seed: 1})
+	game.state.Food = nil
+
+	// A queued turn is acknowledged on the tick that applies it
+	game.SetDirection(models.Up, 1)
+	if game.state.Ack != 0 {
+		t.Errorf("Expected no ack before the turn is applied, got %d", game.state.Ack)
+	}
+	game.Update()
+	if game.state.Ack != 1 || game.state.Tick != 1 {
+		t.Errorf("Expected ack 1 on tick 1, got ack %d on tick %d", game.state.Ack, game.state.Tick)
+	}
+
+	// A dropped turn with nothing queued is acknowledged straight away
+	game.SetDirection(models.Down, 2)
+	if game.state.Ack != 2 {
+		t.Errorf("Expected dropped turn to be acknowledged, got ack %d", game.state.Ack)
+	}
+
+	// A dropped turn behind a queued one waits for it, keeping acks in order
+	game.SetDirection(models.Left, 3)
+	game.SetDirection(models.Right, 4)
+	if game.state.Ack != 2 {
+		t.Errorf("Expected ack to wait for the queued turn, got %d", game.state.Ack)
+	}
+	game.Update()
+	if game.state.Ack != 4 || game.state.Direction != models.Left {
+		t.Errorf("Expected ack 4 heading left, got ack %d heading %s", game.state.Ack, game.state.Direction)
+	}
+
+	// Inputs keep their numbers after a restart
+	game.Reset()
+	if game.state.Ack != 4 || game.state.Tick != 0 {
+		t.Errorf("Expected ack 4 on tick 0 after reset, got ack %d on tick %d", game.state.Ack, game.state.Tick)
+	}
+}
+
+func TestResetNewGameID(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, InitialX: 10, InitialY: 10, Seed: 1})
+	id := game.state.ID
+	if id == "" {
+		t.Fatal("Expected new game to have an ID")
+	}
+
+	game.state.Score = 7
+	game.state.GameOver = true
+	game.Reset()
+
+	if game.state.ID == "" || game.state.ID == id {
+		t.Errorf("Expected a new game ID after reset, got %q (was %q)", game.state.ID, id)
+	}
+	if game.state.Score != 0 || game.state.GameOver {
+		t.Errorf("Expected fresh state after reset, got score %d, gameOver %v", game.state.Score, game.state.GameOver)
+	}
+}
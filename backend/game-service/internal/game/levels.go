@@ -0,0 +1,63 @@
+package game
+
+import (
+	"time"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// Level progression defaults, used when the config leaves them unset
+var defaultLevelThresholds = []int{5, 10, 20, 35, 50, 75, 100}
+
+const (
+	defaultLevelSpeedStep = 20 // Milliseconds taken off the tick interval per level
+	defaultMinSpeed       = 60 // Fastest tick interval in milliseconds that levels can reach
+)
+
+// normalizeLevels fills in the level progression defaults
+// An explicitly empty threshold list disables levels
+func normalizeLevels(config models.GameConfig) models.GameConfig {
+	if config.LevelThresholds == nil {
+		config.LevelThresholds = defaultLevelThresholds
+	}
+	if config.LevelSpeedStep <= 0 {
+		config.LevelSpeedStep = defaultLevelSpeedStep
+	}
+	if config.MinSpeed <= 0 {
+		config.MinSpeed = defaultMinSpeed
+	}
+	return config
+}
+
+// levelFor returns the level reached with the given score
+// Level 1 is the start and every threshold passed adds one
+func (g *Game) levelFor(score int) int {
+	level := 1
+	for _, threshold := range g.config.LevelThresholds {
+		if score >= threshold {
+			level++
+		}
+	}
+	return level
+}
+
+// levelSpeed returns the tick interval in milliseconds for the current level
+// Each level shortens the configured speed by LevelSpeedStep down to MinSpeed
+func (g *Game) levelSpeed() int {
+	speed := g.config.Speed - (g.state.Level-1)*g.config.LevelSpeedStep
+	if speed < g.config.MinSpeed {
+		speed = g.config.MinSpeed
+	}
+	if speed > g.config.Speed {
+		speed = g.config.Speed // A MinSpeed above Speed never slows the game down
+	}
+	return speed
+}
+
+// updateSpeed recomputes the level and the reported tick interval
+// Called whenever the score or the active effects may have changed
+// Callers must hold the mutex
+func (g *Game) updateSpeed() {
+	g.state.Level = g.levelFor(g.state.Score)
+	g.state.Speed = int(g.tickInterval() / time.Millisecond)
+}
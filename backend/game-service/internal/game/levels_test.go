@@ -0,0 +1,58 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+func TestLevelProgression(t *testing.T) {
+	game := NewGame(models.GameConfig{
+		GridSize:        20,
+		InitialX:        5,
+		InitialY:        5,
+		Speed:           200,
+		LevelThresholds: []int{1, 3},
+		LevelSpeedStep:  50,
+		MinSpeed:        120,
+		Seed:            1,
+	})
+
+	if game.state.Level != 1 || game.state.Speed != 200 {
+		t.Fatalf("Expected level 1 at 200ms, got level %d at %dms", game.state.Level, game.state.Speed)
+	}
+
+	// Passing the first threshold moves to level 2
+	placeFood(game, models.Point{X: 6, Y: 5}, models.FoodNormal)
+	game.Update()
+	if game.state.Level != 2 || game.state.Speed != 150 {
+		t.Errorf("Expected level 2 at 150ms, got level %d at %dms", game.state.Level, game.state.Speed)
+	}
+	if interval := game.TickInterval(); interval != 150*time.Millisecond {
+		t.Errorf("Expected 150ms tick interval, got %v", interval)
+	}
+
+	// The interval never drops below MinSpeed
+	game.state.Score = 10
+	game.updateSpeed()
+	if game.state.Level != 3 || game.state.Speed != 120 {
+		t.Errorf("Expected level 3 capped at 120ms, got level %d at %dms", game.state.Level, game.state.Speed)
+	}
+
+	// Reset goes back to the first level
+	game.Reset()
+	if game.state.Level != 1 || game.state.Speed != 200 {
+		t.Errorf("Expected level 1 at 200ms after reset, got level %d at %dms", game.state.Level, game.state.Speed)
+	}
+}
+
+func TestLevelsDisabled(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, Speed: 200, LevelThresholds: []int{}, Seed: 1})
+	game.state.Score = 1000
+	game.updateSpeed()
+
+	if game.state.Level != 1 || game.state.Speed != 200 {
+		t.Errorf("Expected no level progression, got level %d at %dms", game.state.Level, game.state.Speed)
+	}
+}
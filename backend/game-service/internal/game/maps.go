@@ -0,0 +1,195 @@
+package game
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// Map describes a fixed board layout
+// Obstacles are wall cells that end the game when the snake runs into them
+type Map struct {
+	Name      string         `json:"name"`      // Human readable map name
+	Obstacles []models.Point `json:"obstacles"` // Wall cells placed on the board
+}
+
+// wallRune marks a wall cell in the text map format
+const wallRune = '#'
+
+// builtinMaps holds the maps that ship with the server
+// Each layout is generated for the configured board dimensions and keeps
+// the centre of the board free so the snake has room to start
+var builtinMaps = map[string]func(width, height int) []models.Point{
+	"box":   boxMap,
+	"cross": crossMap,
+	"maze":  mazeMap,
+}
+
+// IsBuiltinMap reports whether name is one of the maps that ship with the server
+func IsBuiltinMap(name string) bool {
+	_, ok := builtinMaps[name]
+	return ok
+}
+
+// LoadMap resolves a map by name
+// Built-in map names are generated for the given board dimensions, anything
+// else names a file inside dir. When dir is empty only the built-in maps can
+// be used. Maps with walls off the board are rejected
+func LoadMap(name, dir string, width, height int) (*Map, error) {
+	if build, ok := builtinMaps[name]; ok {
+		return &Map{Name: name, Obstacles: build(width, height)}, nil
+	}
+
+	path, err := mapPath(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	m, err := LoadMapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Validate(width, height); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// mapPath returns the path of the map file called name inside dir
+// Absolute paths and names that climb out of the directory are refused
+func mapPath(dir, name string) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("unknown map %q", name)
+	}
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("map %q is outside the maps directory", name)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// Validate reports an error if any wall lies outside a width by height board
+func (m *Map) Validate(width, height int) error {
+	for _, p := range m.Obstacles {
+		if p.X < 0 || p.X >= width || p.Y < 0 || p.Y >= height {
+			return fmt.Errorf("map %q has a wall at (%d,%d) outside the %dx%d board", m.Name, p.X, p.Y, width, height)
+		}
+	}
+	return nil
+}
+
+// LoadMapFile reads a map from disk
+// Files ending in .json use the JSON format, all others the text format
+func LoadMapFile(path string) (*Map, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open map: %v", err)
+	}
+	defer f.Close()
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return ParseJSONMap(name, f)
+	}
+	return ParseTextMap(name, f)
+}
+
+// ParseTextMap parses the text map format
+// Each line is a row of the board and every '#' is a wall cell;
+// any other character is an empty cell
+func ParseTextMap(name string, r io.Reader) (*Map, error) {
+	m := &Map{Name: name}
+	scanner := bufio.NewScanner(r)
+	for y := 0; scanner.Scan(); y++ {
+		for x, c := range []rune(scanner.Text()) {
+			if c == wallRune {
+				m.Obstacles = append(m.Obstacles, models.Point{X: x, Y: y})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read map: %v", err)
+	}
+	return m, nil
+}
+
+// ParseJSONMap parses the JSON map format
+// The document has the same shape as Map; a missing name falls back to the given one
+func ParseJSONMap(name string, r io.Reader) (*Map, error) {
+	var m Map
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid map file: %v", err)
+	}
+	if m.Name == "" {
+		m.Name = name
+	}
+	return &m, nil
+}
+
+// boxMap walls in the outer edge of the board
+func boxMap(width, height int) []models.Point {
+	var walls []models.Point
+	for x := 0; x < width; x++ {
+		walls = append(walls,
+			models.Point{X: x, Y: 0},
+			models.Point{X: x, Y: height - 1},
+		)
+	}
+	for y := 1; y < height-1; y++ {
+		walls = append(walls,
+			models.Point{X: 0, Y: y},
+			models.Point{X: width - 1, Y: y},
+		)
+	}
+	return walls
+}
+
+// crossMap places a cross through the middle of the board
+// The arms stop short of the edges and leave an open area in the centre
+func crossMap(width, height int) []models.Point {
+	var walls []models.Point
+	centreX, centreY := width/2, height/2
+	for y := 2; y < height-2; y++ {
+		if abs(y-centreY) >= height/4 {
+			walls = append(walls, models.Point{X: centreX, Y: y})
+		}
+	}
+	for x := 2; x < width-2; x++ {
+		if abs(x-centreX) >= width/4 {
+			walls = append(walls, models.Point{X: x, Y: centreY})
+		}
+	}
+	return walls
+}
+
+// mazeMap draws horizontal bars every fourth row with the opening
+// alternating between the right and left side of the board
+func mazeMap(width, height int) []models.Point {
+	var walls []models.Point
+	opening := width / 5
+	for y, bar := 3, 0; y < height-2; y, bar = y+4, bar+1 {
+		if y == height/2 {
+			continue // Keep the starting row clear
+		}
+		from, to := 0, width-opening
+		if bar%2 == 1 {
+			from, to = opening, width
+		}
+		for x := from; x < to; x++ {
+			walls = append(walls, models.Point{X: x, Y: y})
+		}
+	}
+	return walls
+}
+
+// abs returns the absolute value of an int
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
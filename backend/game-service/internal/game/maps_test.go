@@ -0,0 +1,118 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+func TestParseTextMap(t *testing.T) {
+	m, err := ParseTextMap("test", strings.NewReader("#..\n.#.\n..#\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []models.Point{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 2}}
+	if len(m.Obstacles) != len(expected) {
+		t.Fatalf("Expected %d obstacles, got %d", len(expected), len(m.Obstacles))
+	}
+	for i, p := range expected {
+		if m.Obstacles[i] != p {
+			t.Errorf("Obstacle %d: expected (%d,%d), got (%d,%d)", i, p.X, p.Y, m.Obstacles[i].X, m.Obstacles[i].Y)
+		}
+	}
+}
+
+func TestParseJSONMap(t *testing.T) {
+	m, err := ParseJSONMap("fallback", strings.NewReader(`{"obstacles":[{"x":3,"y":4}]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if m.Name != "fallback" {
+		t.Errorf("Expected fallback name, got %q", m.Name)
+	}
+	if len(m.Obstacles) != 1 || m.Obstacles[0] != (models.Point{X: 3, Y: 4}) {
+		t.Errorf("Unexpected obstacles: %v", m.Obstacles)
+	}
+
+	if _, err := ParseJSONMap("bad", strings.NewReader("{")); err == nil {
+		t.Error("Expected error for malformed JSON map")
+	}
+}
+
+func TestBuiltinMapsKeepStartFree(t *testing.T) {
+	sizes := []struct{ width, height int }{{20, 20}, {40, 20}, {15, 30}}
+	for name := range builtinMaps {
+		for _, size := range sizes {
+			m, err := LoadMap(name, "", size.width, size.height)
+			if err != nil {
+				t.Fatalf("Loading %s: %v", name, err)
+			}
+			start := models.Point{X: size.width / 2, Y: size.height / 2}
+			next := models.Point{X: start.X + 1, Y: start.Y}
+			for _, p := range m.Obstacles {
+				if p == start || p == next {
+					t.Errorf("Map %s (%dx%d) blocks the starting position at (%d,%d)",
+						name, size.width, size.height, p.X, p.Y)
+				}
+			}
+		}
+	}
+}
+
+func TestLoadMapUnknown(t *testing.T) {
+	if _, err := LoadMap("does-not-exist", "", 20, 20); err == nil {
+		t.Error("Expected error for unknown map")
+	}
+}
+
+func TestLoadMapDir(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "corner.txt"), []byte("#\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "wide.txt"), []byte("....................#\n"), 0o644)
+	outside := filepath.Join(t.TempDir(), "outside.txt")
+	os.WriteFile(outside, []byte("#\n"), 0o644)
+
+	// Without a maps directory only built-in maps load
+	if _, err := LoadMap("corner.txt", "", 20, 20); err == nil {
+		t.Error("Expected map files to be refused without a maps directory")
+	}
+
+	m, err := LoadMap("corner.txt", dir, 20, 20)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(m.Obstacles) != 1 || m.Obstacles[0] != (models.Point{}) {
+		t.Errorf("Unexpected obstacles: %v", m.Obstacles)
+	}
+
+	for _, name := range []string{outside, "../outside.txt", "sub/../../outside.txt"} {
+		if _, err := LoadMap(name, dir, 20, 20); err == nil {
+			t.Errorf("Expected %q to be refused", name)
+		}
+	}
+	if _, err := LoadMap("wide.txt", dir, 20, 20); err == nil {
+		t.Error("Expected a map with a wall off the board to be refused")
+	}
+}
+
+func TestMapOnStartCell(t *testing.T) {
+	// The box map walls in (0,0), so the game falls back to an open board
+	game := NewGame(models.GameConfig{GridSize: 20, Map: "box", InitialX: 0, InitialY: 0})
+	if len(game.state.Obstacles) != 0 || len(game.obstacles) != 0 {
+		t.Errorf("Expected no obstacles when the map blocks the start, got %d", len(game.state.Obstacles))
+	}
+}
+
+func TestMapDirFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "post.txt"), []byte("..\n..#\n"), 0o644)
+
+	game := NewGame(models.GameConfig{GridSize: 20, Map: "post.txt", MapDir: dir})
+	if len(game.state.Obstacles) != 1 {
+		t.Errorf("Expected the map file from the configured directory, got %v", game.state.Obstacles)
+	}
+}
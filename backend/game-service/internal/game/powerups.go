@@ -0,0 +1,177 @@
+package game
+
+import (
+	"time"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// Power-up tuning
+const (
+	powerUpChance   = 40 // One in powerUpChance ticks spawns a power-up while none is on the board
+	powerUpLifetime = 50 // Ticks a power-up stays on the board before disappearing
+	magnetRadius    = 4  // Food within this many cells (Manhattan distance) is pulled toward the head
+	speedFactor     = 2  // Speed boost divides and slow-mo multiplies the tick interval by this
+)
+
+// powerUpDurations holds how many ticks each effect lasts once collected
+var powerUpDurations = map[models.PowerUpType]int{
+	models.PowerUpSpeed:  40,
+	models.PowerUpSlowMo: 40,
+	models.PowerUpGhost:  30,
+	models.PowerUpMagnet: 40,
+}
+
+// powerUpTypes lists the power-up types in a fixed order
+// Rolling over a slice instead of the map keeps seeded games reproducible
+var powerUpTypes = []models.PowerUpType{
+	models.PowerUpSpeed,
+	models.PowerUpSlowMo,
+	models.PowerUpGhost,
+	models.PowerUpMagnet,
+}
+
+// TickInterval returns how long the session should wait before the next Update
+// It starts from the current level's speed and is shortened by speed boost
+// and stretched by slow-mo
+func (g *Game) TickInterval() time.Duration {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.tickInterval()
+}
+
+// tickInterval is TickInterval for callers that already hold the mutex
+func (g *Game) tickInterval() time.Duration {
+	interval := time.Duration(g.levelSpeed()) * time.Millisecond
+	if g.hasEffect(models.PowerUpSpeed) {
+		interval /= speedFactor
+	}
+	if g.hasEffect(models.PowerUpSlowMo) {
+		interval *= speedFactor
+	}
+	return interval
+}
+
+// hasEffect reports whether the given power-up effect is active
+// Callers must hold the mutex
+func (g *Game) hasEffect(t models.PowerUpType) bool {
+	for _, effect := range g.state.Effects {
+		if effect.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// collectPowerUp activates any power-up at the given point
+// Collecting an effect that is already active restarts its duration
+// Returns true if a power-up was collected
+func (g *Game) collectPowerUp(p models.Point) bool {
+	for i, powerUp := range g.state.PowerUps {
+		if powerUp.Point != p {
+			continue
+		}
+
+		// Build new slices so states already handed out by GetState are untouched
+		remaining := make([]models.PowerUp, 0, len(g.state.PowerUps))
+		remaining = append(remaining, g.state.PowerUps[:i]...)
+		g.state.PowerUps = append(remaining, g.state.PowerUps[i+1:]...)
+
+		effects := make([]models.ActiveEffect, 0, len(g.state.Effects)+1)
+		for _, effect := range g.state.Effects {
+			if effect.Type != powerUp.Type {
+				effects = append(effects, effect)
+			}
+		}
+		g.state.Effects = append(effects, models.ActiveEffect{
+			Type:      powerUp.Type,
+			TicksLeft: powerUpDurations[powerUp.Type],
+		})
+		return true
+	}
+	return false
+}
+
+// tickPowerUps ages active effects and power-ups on the board
+// Expired entries are dropped and, when power-ups are enabled, a new one
+// may spawn if the board has none
+func (g *Game) tickPowerUps() {
+	effects := make([]models.ActiveEffect, 0, len(g.state.Effects))
+	for _, effect := range g.state.Effects {
+		effect.TicksLeft--
+		if effect.TicksLeft > 0 {
+			effects = append(effects, effect)
+		}
+	}
+	g.state.Effects = effects
+
+	powerUps := make([]models.PowerUp, 0, len(g.state.PowerUps)+1)
+	for _, powerUp := range g.state.PowerUps {
+		powerUp.TicksLeft--
+		if powerUp.TicksLeft > 0 {
+			powerUps = append(powerUps, powerUp)
+		}
+	}
+	g.state.PowerUps = powerUps
+
+	if g.config.PowerUps && len(g.state.PowerUps) == 0 && g.rng.Intn(powerUpChance) == 0 {
+		if cell, ok := g.freeCell(); ok {
+			g.state.PowerUps = append(g.state.PowerUps, models.PowerUp{
+				Point:     cell,
+				Type:      powerUpTypes[g.rng.Intn(len(powerUpTypes))],
+				TicksLeft: powerUpLifetime,
+			})
+		}
+	}
+}
+
+// pullFood moves food near the head one step toward it while the magnet is active
+// Food only moves onto free cells; food pulled onto the head is eaten straight away
+func (g *Game) pullFood(head models.Point) {
+	if !g.hasEffect(models.PowerUpMagnet) {
+		return
+	}
+
+	occupied := make(map[string]bool, len(g.state.Snake)+len(g.state.Food))
+	for _, p := range g.state.Snake[1:] {
+		occupied[pointKey(p)] = true
+	}
+	for _, item := range g.state.Food {
+		occupied[pointKey(item.Point)] = true
+	}
+
+	food := make([]models.FoodItem, 0, len(g.state.Food))
+	for _, item := range g.state.Food {
+		dx, dy := head.X-item.X, head.Y-item.Y
+		if abs(dx)+abs(dy) <= magnetRadius {
+			next := item.Point
+			if abs(dx) >= abs(dy) {
+				next.X += sign(dx)
+			} else {
+				next.Y += sign(dy)
+			}
+
+			key := pointKey(next)
+			if !occupied[key] && !g.obstacles[key] {
+				delete(occupied, pointKey(item.Point))
+				occupied[key] = true
+				item.Point = next
+			}
+		}
+		food = append(food, item)
+	}
+	g.state.Food = food
+
+	g.eatFood(head)
+}
+
+// sign returns -1, 0 or 1 depending on the sign of n
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
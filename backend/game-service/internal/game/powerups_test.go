@@ -0,0 +1,106 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+func TestCollectPowerUp(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, InitialX: 5, InitialY: 5, Seed: 1})
+	game.state.PowerUps = []models.PowerUp{{Point: models.Point{X: 6, Y: 5}, Type: models.PowerUpGhost, TicksLeft: 10}}
+
+	game.Update()
+
+	if len(game.state.PowerUps) != 0 {
+		t.Errorf("Expected power-up to be removed from the board, got %v", game.state.PowerUps)
+	}
+	if len(game.state.Effects) != 1 || game.state.Effects[0].Type != models.PowerUpGhost {
+		t.Fatalf("Expected active ghost effect, got %v", game.state.Effects)
+	}
+	if left := game.state.Effects[0].TicksLeft; left != powerUpDurations[models.PowerUpGhost]-1 {
+		t.Errorf("Expected %d ticks left, got %d", powerUpDurations[models.PowerUpGhost]-1, left)
+	}
+}
+
+func TestEffectsExpire(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, InitialX: 0, InitialY: 0, Seed: 1})
+	game.state.Direction = models.Down
+	game.state.Effects = []models.ActiveEffect{{Type: models.PowerUpSpeed, TicksLeft: 2}}
+
+	game.Update()
+	if len(game.state.Effects) != 1 {
+		t.Fatalf("Expected effect to still be active, got %v", game.state.Effects)
+	}
+	game.Update()
+	if len(game.state.Effects) != 0 {
+		t.Errorf("Expected effect to expire, got %v", game.state.Effects)
+	}
+}
+
+func TestGhostPassesThroughSelf(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, Seed: 1})
+	game.state.Snake = []models.Point{{X: 5, Y: 5}, {X: 5, Y: 6}, {X: 5, Y: 7}}
+
+	if !game.checkCollision(models.Point{X: 5, Y: 6}) {
+		t.Fatal("Expected self collision without ghost")
+	}
+
+	game.state.Effects = []models.ActiveEffect{{Type: models.PowerUpGhost, TicksLeft: 5}}
+	if game.checkCollision(models.Point{X: 5, Y: 6}) {
+		t.Error("Expected ghost to pass through its own body")
+	}
+	if !game.checkCollision(models.Point{X: -1, Y: 6}) {
+		t.Error("Expected walls to stay deadly while ghost is active")
+	}
+}
+
+func TestTickInterval(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, Speed: 200, Seed: 1})
+
+	if interval := game.TickInterval(); interval != 200*time.Millisecond {
+		t.Errorf("Expected 200ms, got %v", interval)
+	}
+
+	game.state.Effects = []models.ActiveEffect{{Type: models.PowerUpSlowMo, TicksLeft: 5}}
+	if interval := game.TickInterval(); interval != 400*time.Millisecond {
+		t.Errorf("Expected slow-mo to give 400ms, got %v", interval)
+	}
+
+	game.state.Effects = []models.ActiveEffect{{Type: models.PowerUpSpeed, TicksLeft: 5}}
+	if interval := game.TickInterval(); interval != 100*time.Millisecond {
+		t.Errorf("Expected speed boost to give 100ms, got %v", interval)
+	}
+}
+
+func TestMagnetPullsFood(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, InitialX: 5, InitialY: 5, Seed: 1})
+	game.state.Direction = models.Down
+	game.state.Effects = []models.ActiveEffect{{Type: models.PowerUpMagnet, TicksLeft: 10}}
+	placeFood(game, models.Point{X: 8, Y: 6}, models.FoodNormal)
+
+	game.Update() // Head moves to (5,6), food is pulled to (7,6)
+
+	if f := game.state.Food[0]; f.Point != (models.Point{X: 7, Y: 6}) {
+		t.Fatalf("Expected food pulled to (7,6), got (%d,%d)", f.X, f.Y)
+	}
+
+	// Food out of range stays put
+	placeFood(game, models.Point{X: 15, Y: 15}, models.FoodNormal)
+	game.Update()
+	if f := game.state.Food[0]; f.Point != (models.Point{X: 15, Y: 15}) {
+		t.Errorf("Expected distant food to stay at (15,15), got (%d,%d)", f.X, f.Y)
+	}
+}
+
+func TestPowerUpsDisabledByDefault(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, InitialX: 0, InitialY: 0, Seed: 1})
+	game.state.Direction = models.Down
+	for i := 0; i < 15; i++ {
+		game.Update()
+	}
+	if len(game.state.PowerUps) != 0 {
+		t.Errorf("Expected no power-ups when disabled, got %v", game.state.PowerUps)
+	}
+}
@@ -0,0 +1,135 @@
+package game
+
+import (
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// defaultShrinkMin is the smallest playable area when the config does not set
+// one, and the smallest a config may ask for
+const defaultShrinkMin = 5
+
+// shrinker tracks the playable area of a board that closes in over time
+// Every shrink moves each edge in by one cell. With shrinking disabled the
+// area is the whole board and never changes
+type shrinker struct {
+	bounds    models.Rect // Current playable area
+	every     int         // Ticks between shrinks; zero disables shrinking
+	min       int         // Smallest width and height the area shrinks to
+	ticksLeft int         // Ticks until the next shrink; zero once it cannot shrink further
+}
+
+// newShrinker creates the playable area for a new game
+func newShrinker(config models.GameConfig) shrinker {
+	s := shrinker{
+		bounds: models.Rect{Width: config.Width, Height: config.Height},
+		every:  config.ShrinkEvery,
+		min:    max(config.ShrinkMin, defaultShrinkMin),
+	}
+	if s.canShrink() {
+		s.ticksLeft = s.every
+	}
+	return s
+}
+
+// canShrink reports whether another shrink is scheduled
+func (s shrinker) canShrink() bool {
+	return s.every > 0 && s.bounds.Width-2 >= s.min && s.bounds.Height-2 >= s.min
+}
+
+// next returns the playable area after the next shrink
+func (s shrinker) next() models.Rect {
+	if !s.canShrink() {
+		return s.bounds
+	}
+	return models.Rect{
+		X:      s.bounds.X + 1,
+		Y:      s.bounds.Y + 1,
+		Width:  s.bounds.Width - 2,
+		Height: s.bounds.Height - 2,
+	}
+}
+
+// tick counts down to the next shrink and reports whether the area shrank
+func (s *shrinker) tick() bool {
+	if s.ticksLeft == 0 {
+		return false
+	}
+
+	s.ticksLeft--
+	if s.ticksLeft > 0 {
+		return false
+	}
+
+	s.bounds = s.next()
+	if s.canShrink() {
+		s.ticksLeft = s.every
+	}
+	return true
+}
+
+// state returns the shrink schedule for clients, or nil when shrinking is off
+func (s shrinker) state() *models.ShrinkState {
+	if s.every <= 0 {
+		return nil
+	}
+	return &models.ShrinkState{
+		Bounds:    s.bounds,
+		Next:      s.next(),
+		TicksLeft: s.ticksLeft,
+		Every:     s.every,
+	}
+}
+
+// outside reports whether any part of body lies outside r
+func outside(body []models.Point, r models.Rect) bool {
+	for _, p := range body {
+		if !r.Contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// foodWithin returns a new slice with only the food inside r
+func foodWithin(food []models.FoodItem, r models.Rect) []models.FoodItem {
+	remaining := make([]models.FoodItem, 0, len(food))
+	for _, item := range food {
+		if r.Contains(item.Point) {
+			remaining = append(remaining, item)
+		}
+	}
+	return remaining
+}
+
+// closeIn applies a shrink to the game
+// Food and power-ups left outside the area are removed, and a snake caught
+// outside it by the closing walls dies
+// Callers must hold the mutex
+func (g *Game) closeIn() {
+	g.state.Food = foodWithin(g.state.Food, g.area.bounds)
+
+	powerUps := make([]models.PowerUp, 0, len(g.state.PowerUps))
+	for _, powerUp := range g.state.PowerUps {
+		if g.area.bounds.Contains(powerUp.Point) {
+			powerUps = append(powerUps, powerUp)
+		}
+	}
+	g.state.PowerUps = powerUps
+
+	if outside(g.state.Snake, g.area.bounds) {
+		g.state.GameOver = true
+	}
+}
+
+// closeIn applies a shrink to the arena
+// Food left outside the area is removed, and snakes caught outside it by the
+// closing walls die
+// Callers must hold the mutex
+func (a *Arena) closeIn() {
+	a.food = foodWithin(a.food, a.area.bounds)
+	for _, p := range a.players {
+		if p.alive && outside(p.body, a.area.bounds) {
+			a.kill(p)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+func TestShrinkSchedule(t *testing.T) {
+	area := newShrinker(models.GameConfig{Width: 11, Height: 9, ShrinkEvery: 2, ShrinkMin: 5})
+
+	if area.ticksLeft != 2 {
+		t.Fatalf("Expected first shrink in 2 ticks, got %d", area.ticksLeft)
+	}
+	if area.tick() || !area.tick() {
+		t.Fatal("Expected the area to shrink on the second tick")
+	}
+	if area.bounds != (models.Rect{X: 1, Y: 1, Width: 9, Height: 7}) {
+		t.Errorf("Unexpected bounds after one shrink: %+v", area.bounds)
+	}
+
+	area.tick()
+	area.tick()
+	if area.bounds != (models.Rect{X: 2, Y: 2, Width: 7, Height: 5}) {
+		t.Errorf("Unexpected bounds after two shrinks: %+v", area.bounds)
+	}
+
+	// Height 5 is the minimum, so the area stops shrinking
+	if state := area.state(); state.TicksLeft != 0 || state.Next != state.Bounds {
+		t.Errorf("Expected no further shrinks, got %+v", state)
+	}
+	if area.tick() {
+		t.Error("Expected no shrink past the minimum size")
+	}
+
+	if newShrinker(models.GameConfig{Width: 20, Height: 20}).state() != nil {
+		t.Error("Expected no shrink state when shrinking is off")
+	}
+}
+
+func TestGameShrinks(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 20, InitialX: 10, InitialY: 10, Seed: 1, ShrinkEvery: 1})
+	game.state.Food = []models.FoodItem{newFoodItem(models.FoodNormal, models.Point{X: 0, Y: 3})}
+
+	game.Update()
+	if game.state.Shrink == nil || game.state.Shrink.Bounds.X != 1 {
+		t.Fatalf("Expected the area to shrink in the state, got %+v", game.state.Shrink)
+	}
+	for _, item := range game.state.Food {
+		if !game.state.Shrink.Bounds.Contains(item.Point) {
+			t.Errorf("Food left outside the playable area at (%d,%d)", item.X, item.Y)
+		}
+	}
+
+	// The closed-off edge is now a wall
+	if !game.checkCollision(models.Point{X: 0, Y: 5}) {
+		t.Error("Expected collision with the closed-off edge")
+	}
+
+	// A snake caught by the closing walls dies
+	game.state.Snake = []models.Point{{X: 1, Y: 5}}
+	game.state.Direction = models.Up
+	game.Update()
+	if !game.state.GameOver {
+		t.Error("Expected game over when caught outside the shrinking area")
+	}
+}
+
+func TestBattleRoyaleLastAlive(t *testing.T) {
+	arena := NewArena(models.GameConfig{GridSize: 20, Seed: 1, ShrinkEvery: 100})
+	for _, owner := range []string{"alice", "bob", "carol"} {
+		if err := arena.Join(owner); err != nil {
+			t.Fatalf("Join %s: %v", owner, err)
+		}
+	}
+	arena.food = nil
+	arena.players[0].body, arena.players[0].direction = []models.Point{{X: 0, Y: 2}}, models.Left
+	arena.players[1].body, arena.players[1].direction = []models.Point{{X: 0, Y: 4}}, models.Left
+	arena.players[1].score = 10
+	arena.players[2].body, arena.players[2].direction = []models.Point{{X: 10, Y: 10}}, models.Right
+
+	// Alice and bob hit the wall; carol is the last snake alive
+	arena.Update()
+	state := arena.GetState()
+	if !state.GameOver {
+		t.Fatal("Expected the arena to end with one snake left")
+	}
+	if state.Winner != "carol" {
+		t.Errorf("Expected carol to win as the last snake alive, got %q", state.Winner)
+	}
+}
+
+func TestShrinkMinEnforced(t *testing.T) {
+	area := newShrinker(models.GameConfig{Width: 9, Height: 9, ShrinkEvery: 1, ShrinkMin: 1})
+	for i := 0; i < 10; i++ {
+		area.tick()
+	}
+	if area.bounds.Width != defaultShrinkMin || area.bounds.Height != defaultShrinkMin {
+		t.Errorf("Expected the area to stop at %dx%d, got %+v", defaultShrinkMin, defaultShrinkMin, area.bounds)
+	}
+}
+
+func TestShrunkBoardFullSkipsFood(t *testing.T) {
+	game := NewGame(models.GameConfig{GridSize: 7, Seed: 1, ShrinkEvery: 1, ShrinkMin: 1})
+	game.area.bounds = game.area.next()
+
+	// The snake fills the whole 5x5 playable area
+	game.state.Snake = nil
+	for y := 1; y <= 5; y++ {
+		for x := 1; x <= 5; x++ {
+			game.state.Snake = append(game.state.Snake, models.Point{X: x, Y: y})
+		}
+	}
+	game.state.Food = nil
+	game.generateFood()
+	if len(game.state.Food) != 0 {
+		t.Errorf("Expected no food when the playable area is full, got %v", game.state.Food)
+	}
+}
@@ -0,0 +1,87 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// teamNames are the names of the first teams; clients colour snakes by them
+var teamNames = []string{"red", "blue", "green", "yellow"}
+
+// teamName returns the name of the team at index i
+func teamName(i int) string {
+	if i < len(teamNames) {
+		return teamNames[i]
+	}
+	return fmt.Sprintf("team-%d", i+1)
+}
+
+// assignTeam picks the team with the fewest players for a new snake
+// Ties go to the first team, so teams fill up in order
+// Callers must hold the mutex
+func (a *Arena) assignTeam() string {
+	if a.config.Teams <= 0 {
+		return ""
+	}
+
+	counts := make(map[string]int, a.config.Teams)
+	for _, p := range a.players {
+		if !p.left {
+			counts[p.team]++
+		}
+	}
+	best := teamName(0)
+	for i := 1; i < a.config.Teams; i++ {
+		if name := teamName(i); counts[name] < counts[best] {
+			best = name
+		}
+	}
+	return best
+}
+
+// harmless reports whether p can run into q without dying
+// Teammates are harmless to each other unless friendly fire is on; a snake
+// is never harmless to itself
+func (a *Arena) harmless(p, q *arenaPlayer) bool {
+	return p != q && p.team != "" && p.team == q.team && !a.config.FriendlyFire
+}
+
+// teamStates adds up every team's score from its members' food
+// Teams are listed in order, including ones nobody has joined yet
+// Callers must hold the mutex
+func (a *Arena) teamStates() []models.TeamState {
+	if a.config.Teams <= 0 {
+		return nil
+	}
+
+	teams := make([]models.TeamState, a.config.Teams)
+	index := make(map[string]int, a.config.Teams)
+	for i := range teams {
+		teams[i] = models.TeamState{Name: teamName(i), Players: []string{}}
+		index[teams[i].Name] = i
+	}
+	for _, p := range a.players {
+		team := &teams[index[p.team]]
+		team.Score += p.score
+		team.Players = append(team.Players, p.owner)
+	}
+	return teams
+}
+
+// topTeam returns the team with the highest score
+// Ties go to the first team in order
+// Callers must hold the mutex
+func (a *Arena) topTeam() string {
+	var best *models.TeamState
+	teams := a.teamStates()
+	for i := range teams {
+		if best == nil || teams[i].Score > best.Score {
+			best = &teams[i]
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.Name
+}
@@ -0,0 +1,100 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// newTeamArena creates a two team arena with alice on red and bob on blue
+// Bob's body lies across alice's path
+func newTeamArena(t *testing.T, friendlyFire bool) *Arena {
+	t.Helper()
+	arena := NewArena(models.GameConfig{GridSize: 20, Seed: 1, Teams: 2, FriendlyFire: friendlyFire})
+	for _, owner := range []string{"alice", "bob", "carol"} {
+		if err := arena.Join(owner); err != nil {
+			t.Fatalf("Join %s: %v", owner, err)
+		}
+	}
+	arena.food = nil
+	arena.players[0].body, arena.players[0].direction = []models.Point{{X: 4, Y: 5}}, models.Right
+	arena.players[1].body, arena.players[1].direction = []models.Point{{X: 10, Y: 10}}, models.Right
+	// Carol is alice's teammate and lies across her path
+	arena.players[2].body = []models.Point{{X: 5, Y: 4}, {X: 5, Y: 5}, {X: 5, Y: 6}}
+	arena.players[2].direction = models.Up
+	return arena
+}
+
+func TestTeamAssignment(t *testing.T) {
+	arena := newTeamArena(t, false)
+
+	teams := []string{"red", "blue", "red"}
+	for i, p := range arena.players {
+		if p.team != teams[i] {
+			t.Errorf("Expected %s on team %s, got %s", p.owner, teams[i], p.team)
+		}
+	}
+	if state := arena.GetState(); state.Snakes[0].Team != "red" || len(state.Teams) != 2 {
+		t.Errorf("Expected team info in state, got %+v", state.Teams)
+	}
+}
+
+func TestTeammatesHarmless(t *testing.T) {
+	arena := newTeamArena(t, false)
+	arena.Update()
+	if !arena.players[0].alive {
+		t.Error("Expected alice to pass through her teammate")
+	}
+}
+
+func TestFriendlyFire(t *testing.T) {
+	arena := newTeamArena(t, true)
+	arena.Update()
+	if arena.players[0].alive {
+		t.Error("Expected alice to die running into her teammate with friendly fire on")
+	}
+}
+
+func TestWinningTeam(t *testing.T) {
+	arena := newTeamArena(t, false)
+	arena.players[0].score = 3
+	arena.players[1].score = 5
+	arena.players[2].score = 4
+
+	// Everyone runs into the walls
+	for i := 0; i < 30 && !arena.GetState().GameOver; i++ {
+		arena.Update()
+	}
+	state := arena.GetState()
+	if !state.GameOver {
+		t.Fatal("Expected game over")
+	}
+	if state.Teams[0].Score != 7 || state.Teams[1].Score != 5 {
+		t.Errorf("Expected team scores 7 and 5, got %d and %d", state.Teams[0].Score, state.Teams[1].Score)
+	}
+	if state.WinningTeam != "red" {
+		t.Errorf("Expected red to win, got %q", state.WinningTeam)
+	}
+	if state.Winner != "bob" {
+		t.Errorf("Expected bob to be the top scorer, got %q", state.Winner)
+	}
+}
+
+func TestTeammateLeaves(t *testing.T) {
+	arena := newTeamArena(t, false)
+	arena.players[0].score = 3
+	arena.players[2].score = 4
+	arena.Update()
+
+	arena.Leave("carol")
+	state := arena.GetState()
+	if len(state.Snakes) != 3 || state.Snakes[2].Alive {
+		t.Fatalf("Expected carol to stay listed as dead, got %+v", state.Snakes)
+	}
+	if state.Teams[0].Score != 7 {
+		t.Errorf("Expected red to keep carol's score, got %d", state.Teams[0].Score)
+	}
+	if err := arena.Join("carol"); err == nil {
+		t.Error("Expected error when rejoining after leaving")
+	}
+}
@@ -0,0 +1,323 @@
+package scheduler
+
+import (
+	"container/heap"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// Task is work the scheduler repeats at its own interval
+type Task interface {
+	// Tick runs the task once and returns how long to wait before running it again
+	// A zero or negative interval stops the task
+	Tick(now time.Time) time.Duration
+}
+
+// TaskFunc adapts a function to a Task
+type TaskFunc func(now time.Time) time.Duration
+
+// Tick calls f(now)
+func (f TaskFunc) Tick(now time.Time) time.Duration {
+	return f(now)
+}
+
+// lagWindow is how long the longest lag is remembered for
+const lagWindow = 30 * time.Second
+
+// idleWait is how long a worker with no tasks sleeps before checking again
+const idleWait = time.Minute
+
+// Scheduler runs tasks on a fixed pool of worker loops
+// Each task belongs to one worker, which runs it whenever it is due. Tasks on
+// different workers run in parallel; tasks on the same worker run one after
+// another, so a task must not block. Every worker records how late its tasks
+// start, which is reported as tick lag
+type Scheduler struct {
+	workers   []*worker
+	stop      chan struct{} // Closed to stop every worker
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// Job is a task added to the scheduler
+type Job struct {
+	task    Task
+	worker  *worker   // Worker the task belongs to
+	due     time.Time // When the task should next run
+	index   int       // Position in the worker's queue; -1 while running or once removed
+	removed bool      // True once the task has stopped or been removed
+	moved   bool      // True if the task was rescheduled while running; due holds the new time
+}
+
+// worker is one loop running its share of the tasks
+type worker struct {
+	queue    jobQueue      // Waiting tasks, earliest due first
+	jobs     int           // Tasks that belong to the worker, running ones included
+	wake     chan struct{} // Signalled when a task becomes due earlier than the worker expects
+	ticks    uint64        // Tasks run so far
+	lagTotal time.Duration // Sum of the lag of every run
+	maxLag   time.Duration // Longest lag in the current window
+	prevMax  time.Duration // Longest lag in the previous window
+	window   time.Time     // When the current window started
+	mutex    sync.Mutex    // Mutex for the queue and statistics
+}
+
+// New creates a scheduler with the given number of workers
+// Zero or less means one worker per CPU
+func New(workers int) *Scheduler {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	s := &Scheduler{stop: make(chan struct{})}
+	for i := 0; i < workers; i++ {
+		s.workers = append(s.workers, &worker{wake: make(chan struct{}, 1), window: time.Now()})
+	}
+	return s
+}
+
+// Start starts the worker loops
+// Tasks added before Start wait until it is called
+func (s *Scheduler) Start() {
+	s.startOnce.Do(func() {
+		for _, w := range s.workers {
+			go w.run(s.stop)
+		}
+	})
+}
+
+// Stop stops the worker loops
+// A task that is running finishes first
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// Add schedules a task to first run after the given delay
+// It goes to the worker with the fewest tasks
+func (s *Scheduler) Add(task Task, after time.Duration) *Job {
+	w := s.workers[0]
+	for _, other := range s.workers[1:] {
+		if other.load() < w.load() {
+			w = other
+		}
+	}
+
+	job := &Job{task: task, worker: w, due: time.Now().Add(after)}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.jobs++
+	w.push(job)
+	return job
+}
+
+// Remove stops a task
+// A run that has already started finishes, but the task is not run again
+func (s *Scheduler) Remove(job *Job) {
+	w := job.worker
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if job.removed {
+		return
+	}
+	job.removed = true
+	w.jobs--
+	if job.index >= 0 {
+		heap.Remove(&w.queue, job.index)
+	}
+}
+
+// Reschedule makes a task next run after the given delay instead of when it was due
+func (s *Scheduler) Reschedule(job *Job, after time.Duration) {
+	w := job.worker
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if job.removed {
+		return
+	}
+	job.due = time.Now().Add(after)
+	if job.index < 0 {
+		job.moved = true // Running; the worker queues it again when it returns
+		return
+	}
+	heap.Fix(&w.queue, job.index)
+	if job.index == 0 {
+		w.signal()
+	}
+}
+
+// Stats reports the number of tasks and how late they have been starting
+func (s *Scheduler) Stats() models.SchedulerStats {
+	var stats models.SchedulerStats
+	var lagTotal time.Duration
+	var maxLag time.Duration
+	for _, w := range s.workers {
+		w.mutex.Lock()
+		ws := workerStats(w.jobs, w.ticks, w.lagTotal, max(w.maxLag, w.prevMax))
+		stats.Sessions += w.jobs
+		stats.Ticks += w.ticks
+		lagTotal += w.lagTotal
+		maxLag = max(maxLag, w.maxLag, w.prevMax)
+		w.mutex.Unlock()
+		stats.Workers = append(stats.Workers, ws)
+	}
+	stats.WorkerStats = workerStats(stats.Sessions, stats.Ticks, lagTotal, maxLag)
+	return stats
+}
+
+// workerStats converts lag totals to the figures reported
+func workerStats(sessions int, ticks uint64, lagTotal, maxLag time.Duration) models.WorkerStats {
+	stats := models.WorkerStats{Sessions: sessions, Ticks: ticks, MaxLagMs: milliseconds(maxLag)}
+	if ticks > 0 {
+		stats.MeanLagMs = milliseconds(lagTotal) / float64(ticks)
+	}
+	return stats
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// load returns the number of tasks that belong to the worker
+func (w *worker) load() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.jobs
+}
+
+// push queues a task and wakes the worker if it is now the first one due
+// Callers must hold the mutex
+func (w *worker) push(job *Job) {
+	heap.Push(&w.queue, job)
+	if job.index == 0 {
+		w.signal()
+	}
+}
+
+// signal wakes the worker without waiting for it
+func (w *worker) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run runs due tasks until the scheduler stops
+// Between runs it sleeps until the next task is due or an earlier one is added
+func (w *worker) run(stop <-chan struct{}) {
+	timer := time.NewTimer(idleWait)
+	defer timer.Stop()
+
+	for {
+		wait := idleWait
+		if next := w.runDue(time.Now()); !next.IsZero() {
+			wait = time.Until(next)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-timer.C:
+		case <-w.wake:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// runDue runs every task that is due at now and returns when the next one is
+// The zero time means the worker has no tasks. Tasks run without the mutex so
+// they can be added, removed and rescheduled meanwhile
+func (w *worker) runDue(now time.Time) time.Time {
+	type run struct {
+		job *Job
+		due time.Time
+	}
+
+	w.mutex.Lock()
+	var runs []run
+	for len(w.queue) > 0 && !w.queue[0].due.After(now) {
+		job := heap.Pop(&w.queue).(*Job)
+		runs = append(runs, run{job, job.due})
+	}
+	w.mutex.Unlock()
+
+	for _, r := range runs {
+		start := time.Now()
+		interval := r.job.task.Tick(start)
+
+		w.mutex.Lock()
+		w.record(start.Sub(r.due), start)
+		switch job := r.job; {
+		case job.removed:
+		case job.moved:
+			job.moved = false
+			w.push(job)
+		case interval <= 0:
+			job.removed = true
+			w.jobs--
+		default:
+			// Keep the task's cadence unless it has fallen a whole interval behind
+			job.due = r.due.Add(interval)
+			if job.due.Before(start) {
+				job.due = start.Add(interval)
+			}
+			w.push(job)
+		}
+		w.mutex.Unlock()
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.queue) == 0 {
+		return time.Time{}
+	}
+	return w.queue[0].due
+}
+
+// record adds one run's lag to the statistics
+// Callers must hold the mutex
+func (w *worker) record(lag time.Duration, now time.Time) {
+	w.ticks++
+	w.lagTotal += lag
+	if now.Sub(w.window) >= lagWindow {
+		w.prevMax, w.maxLag, w.window = w.maxLag, 0, now
+	}
+	w.maxLag = max(w.maxLag, lag)
+}
+
+// jobQueue is a min-heap of jobs ordered by when they are due
+type jobQueue []*Job
+
+func (q jobQueue) Len() int           { return len(q) }
+func (q jobQueue) Less(i, j int) bool { return q[i].due.Before(q[j].due) }
+
+func (q jobQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *jobQueue) Push(x interface{}) {
+	job := x.(*Job)
+	job.index = len(*q)
+	*q = append(*q, job)
+}
+
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	job := old[len(old)-1]
+	old[len(old)-1] = nil
+	job.index = -1
+	*q = old[:len(old)-1]
+	return job
+}
@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/snake-game/game-service/internal/game"
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// counter is a task that counts its runs and repeats at a fixed interval
+type counter struct {
+	runs     atomic.Int64
+	interval time.Duration
+}
+
+func (c *counter) Tick(time.Time) time.Duration {
+	c.runs.Add(1)
+	return c.interval
+}
+
+// startScheduler starts a scheduler that is stopped when the test ends
+func startScheduler(t testing.TB, workers int) *Scheduler {
+	s := New(workers)
+	s.Start()
+	t.Cleanup(s.Stop)
+	return s
+}
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("Timed out waiting for %s", what)
+}
+
+func TestPerTaskIntervals(t *testing.T) {
+	s := startScheduler(t, 2)
+	fast := &counter{interval: 10 * time.Millisecond}
+	slow := &counter{interval: 40 * time.Millisecond}
+	s.Add(fast, fast.interval)
+	s.Add(slow, slow.interval)
+
+	time.Sleep(400 * time.Millisecond)
+	f, sl := fast.runs.Load(), slow.runs.Load()
+	if f < 20 || f > 41 {
+		t.Errorf("Expected the fast task to run about 40 times, ran %d", f)
+	}
+	if sl < 5 || sl > 11 {
+		t.Errorf("Expected the slow task to run about 10 times, ran %d", sl)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	s := startScheduler(t, 1)
+	c := &counter{interval: 5 * time.Millisecond}
+	job := s.Add(c, 0)
+	waitFor(t, "the task to run", func() bool { return c.runs.Load() > 0 })
+
+	s.Remove(job)
+	runs := c.runs.Load()
+	time.Sleep(30 * time.Millisecond)
+	if got := c.runs.Load(); got > runs+1 {
+		t.Errorf("Expected a removed task to stop, it ran %d more times", got-runs)
+	}
+	if got := s.Stats().Sessions; got != 0 {
+		t.Errorf("Expected no sessions after removal, got %d", got)
+	}
+	s.Remove(job) // Removing twice is harmless
+}
+
+func TestStopFromTask(t *testing.T) {
+	s := startScheduler(t, 1)
+	c := &counter{}
+	s.Add(c, 0)
+	waitFor(t, "the task to stop", func() bool { return s.Stats().Sessions == 0 })
+
+	time.Sleep(20 * time.Millisecond)
+	if got := c.runs.Load(); got != 1 {
+		t.Errorf("Expected a task returning no interval to run once, ran %d times", got)
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	s := startScheduler(t, 1)
+	c := &counter{interval: time.Hour}
+	job := s.Add(c, time.Hour)
+
+	s.Reschedule(job, 0)
+	waitFor(t, "the rescheduled task to run", func() bool { return c.runs.Load() == 1 })
+}
+
+func TestSpreadsAcrossWorkers(t *testing.T) {
+	s := New(4) // Not started, so the tasks stay queued
+	for i := 0; i < 10; i++ {
+		s.Add(&counter{interval: time.Second}, time.Second)
+	}
+
+	stats := s.Stats()
+	if stats.Sessions != 10 || len(stats.Workers) != 4 {
+		t.Fatalf("Expected 10 sessions on 4 workers, got %+v", stats)
+	}
+	for i, w := range stats.Workers {
+		if w.Sessions < 2 || w.Sessions > 3 {
+			t.Errorf("Worker %d has %d sessions; expected them spread evenly", i, w.Sessions)
+		}
+	}
+}
+
+func TestTickLag(t *testing.T) {
+	s := startScheduler(t, 1)
+
+	// A task that hogs the only worker makes the other one late
+	s.Add(TaskFunc(func(time.Time) time.Duration {
+		time.Sleep(30 * time.Millisecond)
+		return 0
+	}), 0)
+	c := &counter{interval: 5 * time.Millisecond}
+	s.Add(c, 0)
+	waitFor(t, "ticks", func() bool { return c.runs.Load() > 5 })
+
+	stats := s.Stats()
+	if stats.MaxLagMs < 20 {
+		t.Errorf("Expected a lag of about 30ms, got %+v", stats.WorkerStats)
+	}
+	if stats.Ticks < 6 || stats.MeanLagMs <= 0 {
+		t.Errorf("Expected ticks and a mean lag to be recorded, got %+v", stats.WorkerStats)
+	}
+}
+
+// BenchmarkHeadlessSessions ticks 10,000 solo games with no connections at
+// 50ms each. One op is every session ticking once, so ns/op stays near 50ms
+// while the workers keep up; the lag metrics show how late ticks start
+func BenchmarkHeadlessSessions(b *testing.B) {
+	const sessions = 10000
+	s := startScheduler(b, 0)
+
+	var ticks atomic.Int64
+	for i := 0; i < sessions; i++ {
+		g := game.NewGame(models.GameConfig{GridSize: 20, Speed: 50, Seed: int64(i + 1), FoodItems: 3})
+		s.Add(TaskFunc(func(time.Time) time.Duration {
+			g.Update()
+			if g.GetState().GameOver {
+				g.Reset()
+			}
+			ticks.Add(1)
+			return g.TickInterval()
+		}), g.TickInterval())
+	}
+
+	b.ResetTimer()
+	for start := ticks.Load(); ticks.Load()-start < int64(b.N)*sessions; {
+		time.Sleep(time.Millisecond)
+	}
+	b.StopTimer()
+
+	stats := s.Stats()
+	b.ReportMetric(stats.MeanLagMs, "mean-lag-ms")
+	b.ReportMetric(stats.MaxLagMs, "max-lag-ms")
+}
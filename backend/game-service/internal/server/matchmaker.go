@@ -0,0 +1,187 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	ws "github.com/snake-game/game-service/internal/websocket"
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// Matchmaking defaults
+const (
+	defaultMatchSize      = 4                // Players grouped into a match as soon as they are waiting
+	defaultMinMatchSize   = 2                // Fewest players a match starts with once the timeout passes
+	defaultMatchTimeout   = 15 * time.Second // How long the first player in the queue waits for a full match
+	defaultMatchCountdown = 3                // Seconds counted down before a match starts
+	matchCheckInterval    = time.Second      // How often the queue is checked for timed-out players
+)
+
+// matchmaker groups players waiting on /ws/matchmake into arena matches
+// Queued connections are only written to by the matchmaker; once a match is
+// found they are handed to the WebSocket handler and play in a new arena
+type matchmaker struct {
+	handler *ws.Handler       // Handler the matched players are registered with
+	config  models.GameConfig // Preset configuration for every match
+	size    int               // Players grouped into a match as soon as they are waiting
+	minSize int               // Fewest players a match starts with once the timeout passes
+	timeout time.Duration     // How long the first player waits for a full match
+	queue   []*queuedPlayer   // Waiting players in arrival order
+	matches int               // Matches formed so far, used to name their arenas
+	mutex   sync.Mutex        // Mutex for thread-safe access to the queue
+}
+
+// matchArenaPrefix starts the name of every match's arena in the WebSocket handler
+const matchArenaPrefix = "match/"
+
+// queuedPlayer is a connection waiting for a match
+type queuedPlayer struct {
+	peer   *ws.Peer
+	name   string
+	joined time.Time
+}
+
+// newMatchmaker creates a matchmaker that starts matches with the given configuration
+func newMatchmaker(handler *ws.Handler, config models.GameConfig) *matchmaker {
+	config.MinPlayers = 1 // Matched players join one after the other during the countdown
+	config.Countdown = defaultMatchCountdown
+	return &matchmaker{
+		handler: handler,
+		config:  config,
+		size:    defaultMatchSize,
+		minSize: defaultMinMatchSize,
+		timeout: defaultMatchTimeout,
+	}
+}
+
+// Run checks the queue for players who have waited out the timeout
+func (m *matchmaker) Run() {
+	ticker := time.NewTicker(matchCheckInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		m.mutex.Lock()
+		m.match(now)
+		m.mutex.Unlock()
+	}
+}
+
+// join adds a player to the queue and starts a match if enough are waiting
+// It fails if a player with the same name is already queued
+func (m *matchmaker) join(peer *ws.Peer, name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, p := range m.queue {
+		if p.name == name {
+			return fmt.Errorf("player %q is already queued", name)
+		}
+	}
+
+	m.queue = append(m.queue, &queuedPlayer{peer: peer, name: name, joined: time.Now()})
+	log.Printf("Player %s queued for a match. Waiting: %d", name, len(m.queue))
+	m.sendPositions()
+	m.match(time.Now())
+	return nil
+}
+
+// leave removes a player who disconnected while waiting
+// It does nothing once the player has been matched
+func (m *matchmaker) leave(peer *ws.Peer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, p := range m.queue {
+		if p.peer == peer {
+			m.queue = append(m.queue[:i:i], m.queue[i+1:]...)
+			peer.Close()
+			m.sendPositions()
+			return
+		}
+	}
+}
+
+// sendPositions tells every waiting player where they are in the queue
+// Callers must hold the mutex
+func (m *matchmaker) sendPositions() {
+	for i, p := range m.queue {
+		msg := models.MatchmakingMessage{
+			Type:     models.MatchmakingQueued,
+			Position: i + 1,
+			Queued:   len(m.queue),
+		}
+		if err := p.peer.Send(models.MessageQueue, msg); err != nil {
+			log.Printf("Error sending queue position: %v", err)
+		}
+	}
+}
+
+// match starts as many matches as the queue allows
+// Full matches start straight away; a smaller one starts once the first
+// player in the queue has waited for the timeout
+// Callers must hold the mutex
+func (m *matchmaker) match(now time.Time) {
+	for len(m.queue) >= m.size {
+		m.start(m.size)
+	}
+	if len(m.queue) >= m.minSize && now.Sub(m.queue[0].joined) >= m.timeout {
+		m.start(len(m.queue))
+	}
+}
+
+// start takes the first n players off the queue and puts them in a new arena
+// Callers must hold the mutex
+func (m *matchmaker) start(n int) {
+	group := m.queue[:n]
+	m.queue = append([]*queuedPlayer(nil), m.queue[n:]...)
+
+	m.matches++
+	arena := fmt.Sprintf("%s%d", matchArenaPrefix, m.matches)
+	players := make([]string, len(group))
+	for i, p := range group {
+		players[i] = p.name
+	}
+	log.Printf("Match %s found for %v", arena, players)
+
+	found := models.MatchmakingMessage{Type: models.MatchmakingFound, Session: arena, Players: players}
+	for _, p := range group {
+		if err := p.peer.Send(models.MessageMatchFound, found); err != nil {
+			log.Printf("Error sending match to %s: %v", p.name, err)
+			p.peer.Close()
+			continue
+		}
+		m.handler.RegisterArena(p.peer, arena, p.name, m.config)
+	}
+
+	if len(m.queue) > 0 {
+		m.sendPositions()
+	}
+}
+
+// handleMatchmake handles WebSocket connections that wait for a match
+// Clients can pick their player name with "?name=PLAYER"
+func (s *Server) handleMatchmake(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "player-" + newRoomID()
+	}
+
+	peer := s.upgrade(w, r)
+	if peer == nil {
+		return
+	}
+
+	if err := s.matchmaker.join(peer, name); err != nil {
+		log.Printf("Player %s could not queue: %v", name, err)
+		peer.Reject(err)
+		return
+	}
+
+	go func() {
+		s.readMessages(peer)
+		s.matchmaker.leave(peer) // Still queued if the player left before a match was found
+	}()
+}
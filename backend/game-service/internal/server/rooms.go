@@ -0,0 +1,423 @@
+package server
+
+import (
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/snake-game/game-service/internal/game"
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// Room defaults applied when a room is created without them
+const (
+	defaultRoomMinPlayers = 2 // Players a room waits for before counting down
+	defaultRoomMaxPlayers = 8 // Most players a room accepts
+	defaultRoomCountdown  = 3 // Seconds counted down before a room starts
+)
+
+// Limits on rooms and the settings clients may choose for them
+const (
+	maxRooms         = 100             // Most rooms open at once
+	roomIdleTimeout  = 5 * time.Minute // How long a room nobody is playing in is kept
+	roomMinSize      = 10              // Smallest board width and height
+	roomMaxSize      = 100             // Largest board width and height
+	roomMinSpeed     = 50              // Fastest tick interval in milliseconds
+	roomMaxSpeed     = 1000            // Slowest tick interval in milliseconds
+	roomMaxFood      = 20              // Most food items on the board
+	roomMaxPlayers   = 16              // Most players a room may accept
+	roomMaxCountdown = 30              // Longest countdown in seconds
+	roomMaxTeams     = 4               // Most teams players can be split into
+	roomMaxShrink    = 1000            // Most ticks between shrinks
+)
+
+// errTooManyRooms is returned when maxRooms rooms are already open
+var errTooManyRooms = errors.New("too many rooms")
+
+// roomArenaPrefix starts the name of every room's arena in the WebSocket handler
+// It keeps rooms apart from ad-hoc arenas on /ws/arena
+const roomArenaPrefix = "room/"
+
+// room is a named shared arena created through the REST API
+// The arena itself lives in the WebSocket handler while players are connected.
+// A room stays listed after its arena finishes or empties, so players see the
+// result and can come back, and is removed once nobody has played in it for
+// roomIdleTimeout
+type room struct {
+	id       string            // Identifier used in URLs
+	name     string            // Display name
+	config   models.GameConfig // Configuration the room's arena is created with
+	created  time.Time         // When the room was created
+	ended    time.Time         // When the room's arena last finished or emptied; zero until then
+	finished bool              // True once the room's arena has finished; rooms are played once
+	mutex    sync.Mutex        // Mutex for ended and finished, which change as the arena ends
+}
+
+// arena returns the name of the room's arena in the WebSocket handler
+func (r *room) arena() string {
+	return roomArenaPrefix + r.id
+}
+
+// end records that the room's arena finished or emptied
+func (r *room) end(finished bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.ended = time.Now()
+	r.finished = r.finished || finished
+}
+
+// status reports whether the room has finished and when it was last in use
+func (r *room) status() (finished bool, since time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.ended.IsZero() {
+		return r.finished, r.created
+	}
+	return r.finished, r.ended
+}
+
+// roomRegistry keeps track of the named rooms
+type roomRegistry struct {
+	rooms map[string]*room // Rooms by ID
+	order []*room          // Rooms in creation order, for stable listings
+	mutex sync.RWMutex     // Mutex for thread-safe access to the registry
+}
+
+// newRoomRegistry creates an empty room registry
+func newRoomRegistry() *roomRegistry {
+	return &roomRegistry{rooms: make(map[string]*room)}
+}
+
+// create adds a room with the given name and configuration
+// Rooms without a name are named after their ID. Returns errTooManyRooms once
+// maxRooms rooms are open
+func (rr *roomRegistry) create(name string, config models.GameConfig) (*room, error) {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+
+	if len(rr.rooms) >= maxRooms {
+		return nil, errTooManyRooms
+	}
+	r := &room{id: newRoomID(), name: name, config: config, created: time.Now()}
+	if r.name == "" {
+		r.name = r.id
+	}
+	rr.rooms[r.id] = r
+	rr.order = append(rr.order, r)
+	return r, nil
+}
+
+// remove deletes a room; removing a room that is already gone is harmless
+func (rr *roomRegistry) remove(id string) {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+
+	if _, ok := rr.rooms[id]; !ok {
+		return
+	}
+	delete(rr.rooms, id)
+	order := make([]*room, 0, len(rr.order))
+	for _, r := range rr.order {
+		if r.id != id {
+			order = append(order, r)
+		}
+	}
+	rr.order = order
+}
+
+// get looks up a room by ID
+func (rr *roomRegistry) get(id string) (*room, bool) {
+	rr.mutex.RLock()
+	defer rr.mutex.RUnlock()
+
+	r, ok := rr.rooms[id]
+	return r, ok
+}
+
+// list returns every room in creation order
+func (rr *roomRegistry) list() []*room {
+	rr.mutex.RLock()
+	defer rr.mutex.RUnlock()
+
+	return append([]*room(nil), rr.order...)
+}
+
+// newRoomID returns a short random room identifier
+func newRoomID() string {
+	b := make([]byte, 4)
+	if _, err := crand.Read(b); err != nil {
+		log.Printf("Error generating room ID: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// handleArenaEnded records that a room's arena has finished or its last
+// player has left; pruneRooms removes the room later
+// It runs with the WebSocket handler's lock held
+func (s *Server) handleArenaEnded(arena string, finished bool) {
+	id, ok := strings.CutPrefix(arena, roomArenaPrefix)
+	if !ok {
+		return
+	}
+	if r, ok := s.rooms.get(id); ok {
+		r.end(finished)
+	}
+}
+
+// pruneRooms removes rooms nobody has played in for roomIdleTimeout
+// A finished room goes once that long has passed since it finished, any
+// other room once it has been that long without players
+func (s *Server) pruneRooms() {
+	for _, r := range s.rooms.list() {
+		finished, since := r.status()
+		if time.Since(since) < roomIdleTimeout {
+			continue
+		}
+		if _, ok := s.wsHandler.ArenaState(r.arena()); finished || !ok {
+			s.rooms.remove(r.id)
+			log.Printf("Room %s closed", r.id)
+		}
+	}
+}
+
+// roomInfo describes a room together with the live state of its arena
+func (s *Server) roomInfo(r *room) models.RoomInfo {
+	info := models.RoomInfo{
+		ID:         r.id,
+		Name:       r.name,
+		Status:     models.RoomWaiting,
+		Players:    []string{},
+		MinPlayers: r.config.MinPlayers,
+		MaxPlayers: r.config.MaxPlayers,
+		Config:     r.config,
+	}
+
+	if state, ok := s.wsHandler.ArenaState(r.arena()); ok {
+		info.Status = state.Status
+		info.Countdown = state.Countdown
+		for _, snake := range state.Snakes {
+			info.Players = append(info.Players, snake.Owner)
+		}
+	}
+	if finished, _ := r.status(); finished {
+		info.Status = models.RoomFinished // The players may have left since
+	}
+	return info
+}
+
+// createRoomRequest is the body of POST /rooms
+type createRoomRequest struct {
+	Name   string     `json:"name"`
+	Config roomConfig `json:"config"`
+}
+
+// roomConfig holds the settings a client may choose for a room
+// Fields that are left out keep the server's defaults; every other setting is
+// fixed by the server, and a request naming one is refused
+type roomConfig struct {
+	GridSize     *int    `json:"gridSize"`
+	Width        *int    `json:"width"`
+	Height       *int    `json:"height"`
+	Speed        *int    `json:"speed"`
+	Map          *string `json:"map"`
+	WrapAround   *bool   `json:"wrapAround"`
+	FoodItems    *int    `json:"foodItems"`
+	PowerUps     *bool   `json:"powerUps"`
+	MinPlayers   *int    `json:"minPlayers"`
+	MaxPlayers   *int    `json:"maxPlayers"`
+	Countdown    *int    `json:"countdown"`
+	Teams        *int    `json:"teams"`
+	FriendlyFire *bool   `json:"friendlyFire"`
+	ShrinkEvery  *int    `json:"shrinkEvery"`
+	ShrinkMin    *int    `json:"shrinkMin"`
+}
+
+// apply checks the chosen settings and writes them over config
+func (rc roomConfig) apply(config *models.GameConfig) error {
+	if rc.GridSize != nil {
+		config.Width, config.Height = 0, 0 // A chosen grid size replaces the server's board
+	}
+	ints := []struct {
+		name    string
+		value   *int
+		min     int
+		max     int
+		setting *int
+	}{
+		{"gridSize", rc.GridSize, roomMinSize, roomMaxSize, &config.GridSize},
+		{"width", rc.Width, roomMinSize, roomMaxSize, &config.Width},
+		{"height", rc.Height, roomMinSize, roomMaxSize, &config.Height},
+		{"speed", rc.Speed, roomMinSpeed, roomMaxSpeed, &config.Speed},
+		{"foodItems", rc.FoodItems, 1, roomMaxFood, &config.FoodItems},
+		{"minPlayers", rc.MinPlayers, 1, roomMaxPlayers, &config.MinPlayers},
+		{"maxPlayers", rc.MaxPlayers, 1, roomMaxPlayers, &config.MaxPlayers},
+		{"countdown", rc.Countdown, 0, roomMaxCountdown, &config.Countdown},
+		{"teams", rc.Teams, 0, roomMaxTeams, &config.Teams},
+		{"shrinkEvery", rc.ShrinkEvery, 0, roomMaxShrink, &config.ShrinkEvery},
+		{"shrinkMin", rc.ShrinkMin, 1, roomMaxSize, &config.ShrinkMin},
+	}
+	for _, field := range ints {
+		if field.value == nil {
+			continue
+		}
+		if *field.value < field.min || *field.value > field.max {
+			return fmt.Errorf("%s must be between %d and %d", field.name, field.min, field.max)
+		}
+		*field.setting = *field.value
+	}
+
+	if rc.Map != nil {
+		if *rc.Map != "" && !game.IsBuiltinMap(*rc.Map) {
+			return fmt.Errorf("unknown map %q", *rc.Map)
+		}
+		config.Map = *rc.Map
+	}
+	if rc.WrapAround != nil {
+		config.WrapAround = *rc.WrapAround
+	}
+	if rc.PowerUps != nil {
+		config.PowerUps = *rc.PowerUps
+	}
+	if rc.FriendlyFire != nil {
+		config.FriendlyFire = *rc.FriendlyFire
+	}
+	if config.MinPlayers > config.MaxPlayers {
+		return fmt.Errorf("minPlayers must not be more than maxPlayers")
+	}
+	return nil
+}
+
+// handleCreateRoom creates a room and responds with its description
+// Only the settings in roomConfig may be chosen, within their limits
+func (s *Server) handleCreateRoom(w http.ResponseWriter, r *http.Request) {
+	var req createRoomRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid room: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	config := s.config
+	config.MinPlayers = defaultRoomMinPlayers
+	config.MaxPlayers = defaultRoomMaxPlayers
+	config.Countdown = defaultRoomCountdown
+	if err := req.Config.apply(&config); err != nil {
+		http.Error(w, "Invalid room: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.pruneRooms()
+	room, err := s.rooms.create(req.Name, config)
+	if err != nil {
+		http.Error(w, "Too many rooms", http.StatusServiceUnavailable)
+		return
+	}
+	log.Printf("Room %s created with ID %s", room.name, room.id)
+	writeJSON(w, http.StatusCreated, s.roomInfo(room))
+}
+
+// handleListRooms lists every room with its status and players
+func (s *Server) handleListRooms(w http.ResponseWriter, r *http.Request) {
+	s.pruneRooms()
+	rooms := s.rooms.list()
+	infos := make([]models.RoomInfo, 0, len(rooms))
+	for _, room := range rooms {
+		infos = append(infos, s.roomInfo(room))
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+// joinRoomRequest is the body of POST /rooms/{id}/join
+type joinRoomRequest struct {
+	Name string `json:"name"` // Player name to join with
+}
+
+// joinRoomResponse tells a player where to connect to play in a room
+type joinRoomResponse struct {
+	Room models.RoomInfo `json:"room"`
+	URL  string          `json:"url"` // WebSocket path to connect to, including the player name
+}
+
+// handleJoinRoom checks that a player can join a room and returns the
+// WebSocket URL to connect to. The player takes a seat once connected,
+// and leaves the room by closing the connection
+func (s *Server) handleJoinRoom(w http.ResponseWriter, r *http.Request) {
+	room, ok := s.rooms.get(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	var req joinRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		http.Error(w, "Missing player name", http.StatusBadRequest)
+		return
+	}
+
+	info := s.roomInfo(room)
+	if info.Status == models.RoomFinished {
+		http.Error(w, "Room has finished", http.StatusConflict)
+		return
+	}
+	if info.MaxPlayers > 0 && len(info.Players) >= info.MaxPlayers {
+		http.Error(w, "Room is full", http.StatusConflict)
+		return
+	}
+	for _, player := range info.Players {
+		if player == req.Name {
+			http.Error(w, "Player name is taken", http.StatusConflict)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, joinRoomResponse{
+		Room: info,
+		URL:  "/ws?room=" + url.QueryEscape(room.id) + "&name=" + url.QueryEscape(req.Name),
+	})
+}
+
+// handleRoomSocket connects a player to a room's arena
+func (s *Server) handleRoomSocket(w http.ResponseWriter, r *http.Request, id string) {
+	room, ok := s.rooms.get(id)
+	if !ok {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	player := r.URL.Query().Get("name")
+	if player == "" {
+		http.Error(w, "Missing player name", http.StatusBadRequest)
+		return
+	}
+	if s.roomInfo(room).Status == models.RoomFinished {
+		http.Error(w, "Room has finished", http.StatusConflict) // Rooms are played once
+		return
+	}
+
+	peer := s.upgrade(w, r)
+	if peer == nil {
+		return
+	}
+
+	s.wsHandler.RegisterArena(peer, room.arena(), player, room.config)
+	go s.readMessages(peer)
+}
+
+// writeJSON sends v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
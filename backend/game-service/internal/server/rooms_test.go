@@ -0,0 +1,218 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// request sends a request through the server's router and returns the recorded response
+func request(s *Server, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestRooms(t *testing.T) {
+	s := NewServer(models.GameConfig{GridSize: 20, Speed: 150})
+
+	rec := request(s, http.MethodPost, "/rooms", `{"name": "friday", "config": {"maxPlayers": 4}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating a room, got %d: %s", rec.Code, rec.Body)
+	}
+	var created models.RoomInfo
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("Invalid room response: %v", err)
+	}
+	if created.Name != "friday" || created.Status != models.RoomWaiting {
+		t.Errorf("Expected waiting room named friday, got %q (%s)", created.Name, created.Status)
+	}
+	// Fields left out keep the defaults
+	if created.MaxPlayers != 4 || created.MinPlayers != defaultRoomMinPlayers || created.Config.Speed != 150 {
+		t.Errorf("Unexpected room config: %+v", created.Config)
+	}
+
+	rec = request(s, http.MethodGet, "/rooms", "")
+	var rooms []models.RoomInfo
+	if err := json.NewDecoder(rec.Body).Decode(&rooms); err != nil || len(rooms) != 1 {
+		t.Fatalf("Expected one room in listing, got %v (%v)", rooms, err)
+	}
+
+	rec = request(s, http.MethodPost, "/rooms/"+created.ID+"/join", `{"name": "alice"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 joining a room, got %d: %s", rec.Code, rec.Body)
+	}
+	var joined joinRoomResponse
+	json.NewDecoder(rec.Body).Decode(&joined)
+	if joined.URL != "/ws?room="+created.ID+"&name=alice" {
+		t.Errorf("Unexpected WebSocket URL %q", joined.URL)
+	}
+
+	if rec := request(s, http.MethodPost, "/rooms/missing/join", `{"name": "alice"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 joining an unknown room, got %d", rec.Code)
+	}
+	if rec := request(s, http.MethodPost, "/rooms/"+created.ID+"/join", `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 joining without a name, got %d", rec.Code)
+	}
+}
+
+func TestCreateRoomValidation(t *testing.T) {
+	s := NewServer(models.GameConfig{GridSize: 20, Speed: 150})
+
+	invalid := []string{
+		`{"config": {"gridSize": 0}}`,
+		`{"config": {"width": 100000, "height": 100000}}`,
+		`{"config": {"foodItems": 1000}}`,
+		`{"config": {"speed": 1}}`,
+		`{"config": {"map": "/etc/passwd"}}`,
+		`{"config": {"map": "../maps/box"}}`,
+		`{"config": {"shrinkMin": 0}}`,
+		`{"config": {"minPlayers": 5, "maxPlayers": 2}}`,
+		`{"config": {"seed": 5}}`,
+		`{"config": {"resumeGrace": -1}}`,
+		`{"config": {"workers": 1000}}`,
+	}
+	for _, body := range invalid {
+		if rec := request(s, http.MethodPost, "/rooms", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, rec.Code)
+		}
+	}
+	if rooms := s.rooms.list(); len(rooms) != 0 {
+		t.Errorf("Expected no rooms after invalid requests, got %d", len(rooms))
+	}
+
+	rec := request(s, http.MethodPost, "/rooms", `{"config": {"gridSize": 30, "map": "box", "teams": 2, "shrinkEvery": 50, "shrinkMin": 1}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 for a valid room, got %d: %s", rec.Code, rec.Body)
+	}
+	var created models.RoomInfo
+	json.NewDecoder(rec.Body).Decode(&created)
+	if c := created.Config; c.GridSize != 30 || c.Map != "box" || c.Teams != 2 || c.ShrinkEvery != 50 || c.Speed != 150 {
+		t.Errorf("Unexpected room config: %+v", c)
+	}
+}
+
+func TestRoomLimit(t *testing.T) {
+	s := NewServer(models.GameConfig{GridSize: 20, Speed: 150})
+	for i := 0; i < maxRooms; i++ {
+		if rec := request(s, http.MethodPost, "/rooms", `{}`); rec.Code != http.StatusCreated {
+			t.Fatalf("Room %d: expected 201, got %d", i, rec.Code)
+		}
+	}
+	if rec := request(s, http.MethodPost, "/rooms", `{}`); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 once %d rooms are open, got %d", maxRooms, rec.Code)
+	}
+
+	// Rooms nobody joined make way for new ones once they have idled too long
+	s.rooms.list()[0].created = time.Now().Add(-roomIdleTimeout)
+	if rec := request(s, http.MethodPost, "/rooms", `{}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected an idle room to be pruned, got %d", rec.Code)
+	}
+	if rooms := s.rooms.list(); len(rooms) != maxRooms {
+		t.Errorf("Expected %d rooms, got %d", maxRooms, len(rooms))
+	}
+}
+
+// listRoom returns the room with the given ID as listed by GET /rooms
+func listRoom(t *testing.T, s *Server, id string) (models.RoomInfo, bool) {
+	t.Helper()
+	var rooms []models.RoomInfo
+	json.NewDecoder(request(s, http.MethodGet, "/rooms", "").Body).Decode(&rooms)
+	for _, info := range rooms {
+		if info.ID == id {
+			return info, true
+		}
+	}
+	return models.RoomInfo{}, false
+}
+
+// waitArenaGone waits for the room's arena to be closed in the WebSocket handler
+func waitArenaGone(t *testing.T, s *Server, id string) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if _, ok := s.wsHandler.ArenaState(roomArenaPrefix + id); !ok {
+			return
+		}
+	}
+	t.Fatal("Expected the room's arena to close")
+}
+
+func TestRoomKeptWhenEmpty(t *testing.T) {
+	s, ts := startServer(t, models.GameConfig{GridSize: 20, Speed: 50, Seed: 1})
+
+	rec := request(s, http.MethodPost, "/rooms", `{"name": "brief"}`)
+	var created models.RoomInfo
+	json.NewDecoder(rec.Body).Decode(&created)
+
+	conn := dial(t, ts, "/ws?room="+created.ID+"&name=alice")
+	readState(t, conn)
+	conn.Close()
+	waitArenaGone(t, s, created.ID)
+
+	// The creator can come back after dropping out
+	if info, ok := listRoom(t, s, created.ID); !ok || info.Status != models.RoomWaiting {
+		t.Fatalf("Expected the empty room to stay listed as waiting, got %+v", info)
+	}
+	conn = dial(t, ts, "/ws?room="+created.ID+"&name=alice")
+	readState(t, conn)
+	conn.Close()
+	waitArenaGone(t, s, created.ID)
+
+	// Until it has been empty for too long
+	room, _ := s.rooms.get(created.ID)
+	room.ended = time.Now().Add(-roomIdleTimeout)
+	if _, ok := listRoom(t, s, created.ID); ok {
+		t.Error("Expected the room to be removed once it had been empty too long")
+	}
+}
+
+func TestRoomKeptWhenFinished(t *testing.T) {
+	s, ts := startServer(t, models.GameConfig{GridSize: 20, Speed: 20, Seed: 1})
+
+	rec := request(s, http.MethodPost, "/rooms", `{"config": {"gridSize": 10, "minPlayers": 1, "countdown": 0}}`)
+	var created models.RoomInfo
+	json.NewDecoder(rec.Body).Decode(&created)
+
+	// The lone snake runs into a wall, then its player leaves
+	conn := dial(t, ts, "/ws?room="+created.ID+"&name=alice")
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); {
+		if readState(t, conn).GameOver {
+			break
+		}
+	}
+	conn.Close()
+	waitArenaGone(t, s, created.ID)
+
+	if info, ok := listRoom(t, s, created.ID); !ok || info.Status != models.RoomFinished {
+		t.Fatalf("Expected the room to stay listed as finished, got %+v", info)
+	}
+	if rec := request(s, http.MethodPost, "/rooms/"+created.ID+"/join", `{"name": "bob"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 joining a finished room, got %d", rec.Code)
+	}
+
+	room, _ := s.rooms.get(created.ID)
+	room.ended = time.Now().Add(-roomIdleTimeout)
+	if _, ok := listRoom(t, s, created.ID); ok {
+		t.Error("Expected the finished room to be removed after the timeout")
+	}
+}
+
+func TestRoomArenaReserved(t *testing.T) {
+	s := NewServer(models.GameConfig{GridSize: 20, Speed: 150})
+	rec := request(s, http.MethodPost, "/rooms", `{"config": {"minPlayers": 2}}`)
+	var created models.RoomInfo
+	json.NewDecoder(rec.Body).Decode(&created)
+
+	// A room's arena can only be joined through the room
+	if rec := request(s, http.MethodGet, "/ws/arena?id=room/"+created.ID+"&name=x", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 joining a room's arena directly, got %d", rec.Code)
+	}
+	if _, ok := s.wsHandler.ArenaState("room/" + created.ID); ok {
+		t.Error("Expected the room's arena not to be created")
+	}
+}
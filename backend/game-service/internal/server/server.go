@@ -4,37 +4,93 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
-	"github.com/snake-game/game-service/internal/websocket"
+	ws "github.com/snake-game/game-service/internal/websocket"
 	"github.com/snake-game/game-service/pkg/models"
 )
 
 // Server represents the game server
 type Server struct {
-	router  *mux.Router
-	wsHandler *websocket.Handler
-	config  models.GameConfig
+	router     *mux.Router
+	wsHandler  *ws.Handler
+	rooms      *roomRegistry
+	matchmaker *matchmaker
+	config     models.GameConfig
+	heartbeat  ws.Heartbeat // Liveness checks for every WebSocket connection
+	sendQueue  ws.SendQueue // Outgoing queue and slow consumer policy for every WebSocket connection
 }
 
 // upgrader configures WebSocket connections
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    ws.Subprotocols, // Clients that ask for none get the original bare messages
 	CheckOrigin: func(r *http.Request) bool {
 		return true // Allow all origins in development
 	},
 }
 
+// upgrade upgrades an HTTP request to a WebSocket peer
+// The subprotocol agreed here decides whether the peer uses envelopes
+func (s *Server) upgrade(w http.ResponseWriter, r *http.Request) *ws.Peer {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Error upgrading connection: %v", err)
+		return nil
+	}
+	return ws.NewPeer(conn, s.heartbeat, s.sendQueue)
+}
+
+// defaultHeartbeat suits browsers, which answer pings on their own
+var defaultHeartbeat = ws.Heartbeat{
+	PingInterval: 25 * time.Second,
+	PongWait:     60 * time.Second,
+	WriteWait:    10 * time.Second,
+	IdleTimeout:  5 * time.Minute,
+}
+
+// defaultSendQueue holds about a second of states at the fastest speed
+var defaultSendQueue = ws.SendQueue{Size: 64, Policy: ws.DropStates}
+
+// Option changes a setting of a new server
+type Option func(*Server)
+
+// WithHeartbeat sets the liveness checks used for every WebSocket connection
+func WithHeartbeat(heartbeat ws.Heartbeat) Option {
+	return func(s *Server) {
+		s.heartbeat = heartbeat
+	}
+}
+
+// WithSendQueue sets the outgoing queue used for every WebSocket connection
+func WithSendQueue(queue ws.SendQueue) Option {
+	return func(s *Server) {
+		s.sendQueue = queue
+	}
+}
+
 // NewServer creates a new game server instance
-func NewServer(config models.GameConfig) *Server {
+// Settings left out of opts keep their defaults
+func NewServer(config models.GameConfig, opts ...Option) *Server {
 	s := &Server{
-		router:  mux.NewRouter(),
-		config:  config,
+		router:    mux.NewRouter(),
+		rooms:     newRoomRegistry(),
+		config:    config,
+		heartbeat: defaultHeartbeat,
+		sendQueue: defaultSendQueue,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 
-	s.wsHandler = websocket.NewHandler(config)
+	s.wsHandler = ws.NewHandler(config)
+	s.wsHandler.OnArenaEnded(s.handleArenaEnded)
+	s.matchmaker = newMatchmaker(s.wsHandler, config)
 	s.setupRoutes()
 	return s
 }
@@ -42,39 +98,146 @@ func NewServer(config models.GameConfig) *Server {
 // setupRoutes configures the server routes
 func (s *Server) setupRoutes() {
 	s.router.HandleFunc("/ws", s.handleWebSocket)
+	s.router.HandleFunc("/ws/arena", s.handleArena)
+	s.router.HandleFunc("/ws/spectate", s.handleSpectate)
+	s.router.HandleFunc("/ws/matchmake", s.handleMatchmake)
+	s.router.HandleFunc("/sessions", s.handleListSessions).Methods(http.MethodGet)
+	s.router.HandleFunc("/stats/scheduler", s.handleSchedulerStats).Methods(http.MethodGet)
+	s.router.HandleFunc("/rooms", s.handleCreateRoom).Methods(http.MethodPost)
+	s.router.HandleFunc("/rooms", s.handleListRooms).Methods(http.MethodGet)
+	s.router.HandleFunc("/rooms/{id}/join", s.handleJoinRoom).Methods(http.MethodPost)
 	s.router.HandleFunc("/health", s.handleHealth)
 }
 
 // handleWebSocket handles WebSocket connections
+// Clients can ask for a specific game with "?seed=N" to replay its food sequence,
+// play in a room with "?room=ID&name=PLAYER", or reconnect to a dropped game
+// with "?resume=TOKEN"
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("Error upgrading connection: %v", err)
+	if id := r.URL.Query().Get("room"); id != "" {
+		s.handleRoomSocket(w, r, id)
+		return
+	}
+	if token := r.URL.Query().Get("resume"); token != "" {
+		peer := s.upgrade(w, r)
+		if peer == nil {
+			return
+		}
+		s.wsHandler.Resume(peer, token)
+		go s.readMessages(peer)
 		return
 	}
 
-	s.wsHandler.Register <- conn
+	config := s.config
+	if seed := r.URL.Query().Get("seed"); seed != "" {
+		value, err := strconv.ParseInt(seed, 10, 64)
+		if err != nil || value < 0 || value > models.MaxSeed {
+			http.Error(w, "Invalid seed", http.StatusBadRequest)
+			return
+		}
+		config.Seed = value
+	}
 
-	// Handle incoming messages
-	go func() {
-		defer func() {
-			s.wsHandler.Unregister <- conn
-		}()
+	peer := s.upgrade(w, r)
+	if peer == nil {
+		return
+	}
 
-		for {
-			_, msg, err := conn.ReadMessage()
-			if err != nil {
-				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					log.Printf("Error reading message: %v", err)
-				}
-				break
-			}
+	s.wsHandler.Register(peer, config)
+	go s.readMessages(peer)
+}
 
-			if err := s.wsHandler.HandleDirection(conn, msg); err != nil {
-				log.Printf("Error handling direction: %v", err)
-			}
+// defaultArena is the arena players join when they do not name one
+const defaultArena = "lobby"
+
+// reservedArenaPrefixes start the names of arenas the server creates for rooms
+// and matches, which can only be joined through /ws?room= and /ws/matchmake
+var reservedArenaPrefixes = []string{roomArenaPrefix, matchArenaPrefix}
+
+// handleArena handles WebSocket connections to a shared arena
+// Clients pick the arena with "?id=NAME" and their snake's owner with "?name=PLAYER"
+func (s *Server) handleArena(w http.ResponseWriter, r *http.Request) {
+	arena := r.URL.Query().Get("id")
+	if arena == "" {
+		arena = defaultArena
+	}
+	for _, prefix := range reservedArenaPrefixes {
+		if strings.HasPrefix(arena, prefix) {
+			http.Error(w, "Reserved arena name", http.StatusBadRequest)
+			return
+		}
+	}
+	player := r.URL.Query().Get("name")
+	if player == "" {
+		http.Error(w, "Missing player name", http.StatusBadRequest)
+		return
+	}
+
+	peer := s.upgrade(w, r)
+	if peer == nil {
+		return
+	}
+
+	s.wsHandler.RegisterArena(peer, arena, player, s.config)
+	go s.readMessages(peer)
+}
+
+// handleSpectate handles WebSocket connections that watch a session without playing
+// Clients pick a session from GET /sessions with "?session=ID", or a room with "?room=ID"
+func (s *Server) handleSpectate(w http.ResponseWriter, r *http.Request) {
+	session := r.URL.Query().Get("session")
+	if id := r.URL.Query().Get("room"); id != "" {
+		room, ok := s.rooms.get(id)
+		if !ok {
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
 		}
+		session = room.arena()
+	}
+	if session == "" {
+		http.Error(w, "Missing session", http.StatusBadRequest)
+		return
+	}
+
+	peer := s.upgrade(w, r)
+	if peer == nil {
+		return
+	}
+
+	s.wsHandler.RegisterSpectator(peer, session)
+	go s.readMessages(peer)
+}
+
+// handleListSessions lists the running sessions spectators can watch
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.wsHandler.Sessions())
+}
+
+// handleSchedulerStats reports tick lag of the game loop's workers
+func (s *Server) handleSchedulerStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.wsHandler.SchedulerStats())
+}
+
+// readMessages handles incoming messages until the connection closes
+// The heartbeat's read deadline ends it when the connection has gone silent
+func (s *Server) readMessages(peer *ws.Peer) {
+	defer func() {
+		s.wsHandler.Unregister(peer)
 	}()
+
+	for {
+		msg, err := peer.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("Error reading message: %v", err)
+			}
+			break
+		}
+
+		if err := s.wsHandler.HandleMessage(peer, msg); err != nil {
+			log.Printf("Error handling message: %v", err)
+		}
+	}
 }
 
 // handleHealth handles health check requests
@@ -85,6 +248,7 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 // Start starts the server
 func (s *Server) Start(port string) error {
 	go s.wsHandler.Run()
+	go s.matchmaker.Run()
 	log.Printf("Server starting on %s", port)
 	return http.ListenAndServe(port, s.router)
 }
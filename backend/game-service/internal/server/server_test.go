@@ -0,0 +1,520 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	ws "github.com/snake-game/game-service/internal/websocket"
+	"github.com/snake-game/game-service/pkg/models"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// startServer runs the server's handler loop and serves its routes over HTTP
+func startServer(t *testing.T, config models.GameConfig, opts ...Option) (*Server, *httptest.Server) {
+	t.Helper()
+	s := NewServer(config, opts...)
+	go s.wsHandler.Run()
+	ts := httptest.NewServer(s.router)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+// dial opens a WebSocket connection to path on the test server
+func dial(t *testing.T, ts *httptest.Server, path string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+path, nil)
+	if err != nil {
+		t.Fatalf("Dial %s: %v", path, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readState reads the next game state from conn
+func readState(t *testing.T, conn *websocket.Conn) models.GameState {
+	t.Helper()
+	var state models.GameState
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&state); err != nil {
+		t.Fatalf("Reading state: %v", err)
+	}
+	return state
+}
+
+// sessions fetches GET /sessions
+func sessions(t *testing.T, ts *httptest.Server) []models.SessionInfo {
+	t.Helper()
+	resp, err := http.Get(ts.URL + "/sessions")
+	if err != nil {
+		t.Fatalf("GET /sessions: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var infos []models.SessionInfo
+	if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+		t.Fatalf("Invalid sessions response: %v", err)
+	}
+	return infos
+}
+
+func TestSpectator(t *testing.T) {
+	_, ts := startServer(t, models.GameConfig{GridSize: 20, InitialX: 10, InitialY: 10, Speed: 50, Seed: 1})
+
+	player := dial(t, ts, "/ws")
+	state := readState(t, player)
+
+	listed := sessions(t, ts)
+	if len(listed) != 1 || listed[0].ID != state.ID || listed[0].Players != 1 {
+		t.Fatalf("Expected the player's game in the session listing, got %+v", listed)
+	}
+
+	spectator := dial(t, ts, "/ws/spectate?session="+state.ID)
+	watched := readState(t, spectator)
+	if watched.ID != state.ID {
+		t.Errorf("Expected spectator to watch %s, got %s", state.ID, watched.ID)
+	}
+
+	// Directions from the spectator are ignored
+	spectator.WriteJSON(map[string]string{"direction": string(models.Down)})
+	for i := 0; i < 3; i++ {
+		watched = readState(t, spectator)
+	}
+	if watched.Direction != models.Right {
+		t.Errorf("Expected spectator input to be ignored, direction is %s", watched.Direction)
+	}
+
+	if listed := sessions(t, ts); listed[0].Spectators != 1 {
+		t.Errorf("Expected 1 spectator in the listing, got %d", listed[0].Spectators)
+	}
+}
+
+// readMatchmaking reads the next matchmaking message from conn
+func readMatchmaking(t *testing.T, conn *websocket.Conn) models.MatchmakingMessage {
+	t.Helper()
+	var msg models.MatchmakingMessage
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Reading matchmaking message: %v", err)
+	}
+	return msg
+}
+
+func TestMatchmaking(t *testing.T) {
+	s, ts := startServer(t, models.GameConfig{GridSize: 20, Speed: 50, Seed: 1})
+	s.matchmaker.size = 2
+
+	alice := dial(t, ts, "/ws/matchmake?name=alice")
+	if msg := readMatchmaking(t, alice); msg.Type != models.MatchmakingQueued || msg.Position != 1 {
+		t.Fatalf("Expected alice first in the queue, got %+v", msg)
+	}
+
+	bob := dial(t, ts, "/ws/matchmake?name=bob")
+	if msg := readMatchmaking(t, bob); msg.Type != models.MatchmakingQueued || msg.Position != 2 {
+		t.Fatalf("Expected bob second in the queue, got %+v", msg)
+	}
+
+	readMatchmaking(t, alice) // Queue update after bob joined
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		msg := readMatchmaking(t, conn)
+		if msg.Type != models.MatchmakingFound || len(msg.Players) != 2 {
+			t.Fatalf("Expected a two player match, got %+v", msg)
+		}
+	}
+
+	// Game states follow on the same connection
+	state := readState(t, alice)
+	if len(state.Snakes) != 2 || state.Status != models.RoomCountdown {
+		t.Errorf("Expected both snakes counting down, got %d snakes (%s)", len(state.Snakes), state.Status)
+	}
+}
+
+func TestMatchmakingTimeout(t *testing.T) {
+	s, ts := startServer(t, models.GameConfig{GridSize: 20, Speed: 50, Seed: 1})
+
+	alice := dial(t, ts, "/ws/matchmake?name=alice")
+	bob := dial(t, ts, "/ws/matchmake?name=bob")
+	readMatchmaking(t, alice)
+	readMatchmaking(t, alice)
+	readMatchmaking(t, bob)
+
+	// Two players are short of a full match until the first has waited long enough
+	s.matchmaker.mutex.Lock()
+	s.matchmaker.match(time.Now().Add(defaultMatchTimeout))
+	s.matchmaker.mutex.Unlock()
+
+	if msg := readMatchmaking(t, bob); msg.Type != models.MatchmakingFound {
+		t.Errorf("Expected a match after the timeout, got %+v", msg)
+	}
+}
+
+func TestMatchArenaReserved(t *testing.T) {
+	_, ts := startServer(t, models.GameConfig{GridSize: 20, Speed: 50, Seed: 1})
+
+	// Matches can only be joined through the matchmaker
+	resp, err := http.Get(ts.URL + "/ws/arena?id=match/1&name=mallory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 joining a match's arena directly, got %d", resp.StatusCode)
+	}
+}
+
+func TestArenaRestartsAfterFinish(t *testing.T) {
+	_, ts := startServer(t, models.GameConfig{GridSize: 10, Speed: 20, Seed: 1})
+
+	// Alice's snake runs into a wall while she stays connected
+	alice := dial(t, ts, "/ws/arena?name=alice")
+	for deadline := time.Now().Add(3 * time.Second); ; {
+		if readState(t, alice).GameOver {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the lobby to finish")
+		}
+	}
+
+	// The next player starts a fresh lobby instead of being turned away
+	bob := dial(t, ts, "/ws/arena?name=bob")
+	state := readState(t, bob)
+	if state.GameOver || len(state.Snakes) != 1 || state.Snakes[0].Owner != "bob" {
+		t.Errorf("Expected bob alone in a fresh lobby, got %+v", state)
+	}
+}
+
+func TestEnvelopeProtocol(t *testing.T) {
+	_, ts := startServer(t, models.GameConfig{GridSize: 20, InitialX: 10, InitialY: 10, Speed: 50, Seed: 1})
+
+	dialer := websocket.Dialer{Subprotocols: []string{models.ProtocolV1}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != models.ProtocolV1 {
+		t.Fatalf("Expected %s to be agreed, got %q", models.ProtocolV1, conn.Subprotocol())
+	}
+
+	// readEnvelope skips to the next envelope of the given type
+	readEnvelope := func(want models.MessageType) models.Envelope {
+		t.Helper()
+		for {
+			var env models.Envelope
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			if err := conn.ReadJSON(&env); err != nil {
+				t.Fatalf("Reading envelope: %v", err)
+			}
+			if env.Type == want {
+				return env
+			}
+		}
+	}
+
+	first := readEnvelope(models.MessageState)
+	second := readEnvelope(models.MessageState)
+	if first.Seq == 0 || second.Seq <= first.Seq {
+		t.Errorf("Expected increasing sequence numbers, got %d then %d", first.Seq, second.Seq)
+	}
+	var state models.GameState
+	if err := json.Unmarshal(first.Payload, &state); err != nil || state.ID == "" {
+		t.Errorf("Expected a game state payload, got %s (%v)", first.Payload, err)
+	}
+
+	// Unknown messages are answered with an error that refers to them
+	conn.WriteJSON(models.Envelope{Type: "teleport", Seq: 7})
+	env := readEnvelope(models.MessageError)
+	var payload models.ErrorPayload
+	json.Unmarshal(env.Payload, &payload)
+	if payload.Ref != 7 || payload.Message == "" {
+		t.Errorf("Expected an error referring to message 7, got %+v", payload)
+	}
+
+	// Commands are wrapped too
+	body, _ := json.Marshal(models.CommandPayload{Command: models.CommandPause})
+	conn.WriteJSON(models.Envelope{Type: models.MessageCommand, Seq: 8, Payload: body})
+	for i := 0; i < 10; i++ {
+		json.Unmarshal(readEnvelope(models.MessageState).Payload, &state)
+		if state.Paused {
+			return
+		}
+	}
+	t.Error("Expected the game to pause")
+}
+
+func TestDeltaResync(t *testing.T) {
+	_, ts := startServer(t, models.GameConfig{GridSize: 20, InitialX: 2, InitialY: 10, Speed: 20, Seed: 1})
+
+	dialer := websocket.Dialer{Subprotocols: []string{models.ProtocolV1Delta}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	read := func() models.Envelope {
+		t.Helper()
+		var env models.Envelope
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.ReadJSON(&env); err != nil {
+			t.Fatalf("Reading envelope: %v", err)
+		}
+		return env
+	}
+
+	if env := read(); env.Type != models.MessageState {
+		t.Fatalf("Expected a keyframe first, got %s", env.Type)
+	}
+	if env := read(); env.Type != models.MessageDelta {
+		t.Fatalf("Expected a delta after the keyframe, got %s", env.Type)
+	}
+
+	conn.WriteJSON(models.Envelope{Type: models.MessageResync, Seq: 1})
+	for i := 0; i < 5; i++ {
+		if read().Type == models.MessageState {
+			return
+		}
+	}
+	t.Error("Expected a keyframe after asking to resync")
+}
+
+func TestMsgpackProtocol(t *testing.T) {
+	_, ts := startServer(t, models.GameConfig{GridSize: 20, InitialX: 10, InitialY: 10, Speed: 50, Seed: 1})
+
+	dialer := websocket.Dialer{Subprotocols: []string{models.ProtocolV1MsgPack}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Envelopes carry their payload inline, named after the JSON fields
+	type envelope struct {
+		Type    models.MessageType `msgpack:"type"`
+		Seq     uint64             `msgpack:"seq"`
+		Payload struct {
+			ID        string           `msgpack:"id"`
+			Direction models.Direction `msgpack:"direction"`
+			Snake     []models.Point   `msgpack:"snake"`
+		} `msgpack:"payload"`
+	}
+	read := func() envelope {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		frame, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Reading envelope: %v", err)
+		}
+		if frame != websocket.BinaryMessage {
+			t.Fatalf("Expected a binary frame, got type %d", frame)
+		}
+		var env envelope
+		if err := msgpack.Unmarshal(data, &env); err != nil {
+			t.Fatalf("Decoding envelope: %v", err)
+		}
+		return env
+	}
+
+	env := read()
+	if env.Type != models.MessageState || env.Payload.ID == "" || len(env.Payload.Snake) == 0 {
+		t.Fatalf("Expected a game state, got %+v", env)
+	}
+
+	cmd, _ := msgpack.Marshal(map[string]interface{}{
+		"type":    models.MessageDirection,
+		"seq":     1,
+		"payload": map[string]string{"direction": string(models.Down)},
+	})
+	conn.WriteMessage(websocket.BinaryMessage, cmd)
+	for i := 0; i < 10; i++ {
+		if read().Payload.Direction == models.Down {
+			return
+		}
+	}
+	t.Error("Expected the binary direction to turn the snake")
+}
+
+func TestResume(t *testing.T) {
+	_, ts := startServer(t, models.GameConfig{GridSize: 20, InitialX: 2, InitialY: 10, Speed: 20, Seed: 1, ResumeGrace: 1})
+
+	first := dial(t, ts, "/ws")
+	state := readState(t, first)
+	if state.ResumeToken == "" {
+		t.Fatal("Expected a resume token in the first state")
+	}
+	if next := readState(t, first); next.ResumeToken != "" {
+		t.Error("Expected the resume token only in the first state")
+	}
+	first.Close()
+
+	// The game waits, paused, for its player
+	waitFor := func(cond func([]models.SessionInfo) bool) {
+		t.Helper()
+		for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+			if cond(sessions(t, ts)) {
+				return
+			}
+		}
+		t.Fatalf("Sessions did not change as expected: %+v", sessions(t, ts))
+	}
+	waitFor(func(listed []models.SessionInfo) bool { return len(listed) == 1 && listed[0].Players == 0 })
+
+	second := dial(t, ts, "/ws?resume="+state.ResumeToken)
+	resumed := readState(t, second)
+	if resumed.ID != state.ID || !resumed.Paused || resumed.ResumeToken != state.ResumeToken {
+		t.Fatalf("Expected to resume paused game %s, got %s (paused %v)", state.ID, resumed.ID, resumed.Paused)
+	}
+
+	// Once the grace period is over the token no longer works
+	second.Close()
+	waitFor(func(listed []models.SessionInfo) bool { return len(listed) == 0 })
+	expired := dial(t, ts, "/ws?resume="+state.ResumeToken)
+	expired.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := expired.ReadMessage(); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	_, ts := startServer(t, models.GameConfig{GridSize: 20, InitialX: 2, InitialY: 10, Speed: 20, Seed: 1})
+
+	conn := dial(t, ts, "/ws")
+	state := readState(t, conn)
+	conn.WriteJSON(models.CommandPayload{Command: models.CommandRestart})
+
+	// States from the old game may still be on their way
+	for i := 0; i < 20; i++ {
+		restarted := readState(t, conn)
+		if restarted.ID == state.ID {
+			continue
+		}
+		if listed := sessions(t, ts); len(listed) != 1 || listed[0].ID != restarted.ID || listed[0].Players != 1 {
+			t.Errorf("Expected only the restarted game with its one player, got %+v", listed)
+		}
+		return
+	}
+	t.Error("Expected a new game ID after restarting")
+}
+
+func TestInputAck(t *testing.T) {
+	_, ts := startServer(t, models.GameConfig{GridSize: 20, InitialX: 10, InitialY: 10, Speed: 20, Seed: 1})
+
+	conn := dial(t, ts, "/ws")
+	state := readState(t, conn)
+
+	conn.WriteJSON(models.DirectionPayload{Direction: models.Up, Seq: 1, Tick: state.Tick})
+	for i := 0; i < 10; i++ {
+		if state = readState(t, conn); state.Ack == 1 {
+			if state.Direction != models.Up {
+				t.Errorf("Expected the acknowledged turn to be applied, heading %s", state.Direction)
+			}
+			return
+		}
+	}
+	t.Errorf("Expected input 1 to be acknowledged, last ack %d", state.Ack)
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+	_, ts := startServer(t, models.GameConfig{GridSize: 20, Speed: 50, Seed: 1, ResumeGrace: -1},
+		WithHeartbeat(ws.Heartbeat{PingInterval: 20 * time.Millisecond, PongWait: 100 * time.Millisecond}))
+
+	// A client that never reads never answers pings, like a dead connection
+	dial(t, ts, "/ws")
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if len(sessions(t, ts)) == 0 {
+			return
+		}
+	}
+	t.Error("Expected the silent connection's game to be closed")
+}
+
+func TestIdleTimeout(t *testing.T) {
+	_, ts := startServer(t, models.GameConfig{GridSize: 20, InitialX: 1, Speed: 50, Seed: 1},
+		WithHeartbeat(ws.Heartbeat{IdleTimeout: 100 * time.Millisecond}))
+
+	// The player keeps reading states but never sends any input
+	conn := dial(t, ts, "/ws")
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Text != string(ws.ReasonIdle) {
+			t.Errorf("Expected to be disconnected as idle, got %v", err)
+		}
+		return
+	}
+}
+
+func TestPausedNotIdle(t *testing.T) {
+	_, ts := startServer(t, models.GameConfig{GridSize: 20, InitialX: 1, Speed: 50, Seed: 1},
+		WithHeartbeat(ws.Heartbeat{IdleTimeout: 100 * time.Millisecond}))
+
+	// The player pauses and then sends nothing for well over the idle timeout
+	conn := dial(t, ts, "/ws")
+	readState(t, conn)
+	conn.WriteJSON(models.CommandPayload{Command: models.CommandPause})
+	conn.SetReadDeadline(time.Now().Add(2500 * time.Millisecond))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Errorf("Expected a paused player to stay connected, got %v", err)
+		}
+		return
+	}
+}
+
+func TestSchedulerStats(t *testing.T) {
+	_, ts := startServer(t, models.GameConfig{GridSize: 20, InitialX: 2, InitialY: 10, Speed: 20, Seed: 1, Workers: 2})
+
+	conn := dial(t, ts, "/ws")
+	readState(t, conn)
+	readState(t, conn)
+
+	resp, err := http.Get(ts.URL + "/stats/scheduler")
+	if err != nil {
+		t.Fatalf("GET /stats/scheduler: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var stats models.SchedulerStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("Invalid stats response: %v", err)
+	}
+	if stats.Sessions != 1 || stats.Ticks < 2 || len(stats.Workers) != 2 {
+		t.Errorf("Expected one session ticked on two workers, got %+v", stats)
+	}
+}
+
+func TestSeedOutOfRange(t *testing.T) {
+	_, ts := startServer(t, models.GameConfig{GridSize: 20, Speed: 50})
+	for _, seed := range []string{"-1", "9007199254740992", "abc"} {
+		resp, err := http.Get(ts.URL + "/ws?seed=" + seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Seed %s: expected status 400, got %d", seed, resp.StatusCode)
+		}
+	}
+
+	// Zero picks a fresh seed that JavaScript clients can hold exactly
+	state := readState(t, dial(t, ts, "/ws?seed=0"))
+	if state.Seed <= 0 || state.Seed > models.MaxSeed {
+		t.Errorf("Expected a fresh seed within 53 bits, got %d", state.Seed)
+	}
+}
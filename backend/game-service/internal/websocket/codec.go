@@ -0,0 +1,119 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+	"github.com/snake-game/game-service/pkg/models"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// codec is a wire encoding for envelopes and their payloads
+// Both encodings use the JSON field names, so the messages have the same
+// shape whichever one a client picks
+type codec struct {
+	frame   int                                          // WebSocket message type of every frame
+	marshal func(v interface{}) ([]byte, error)          // Encodes a value
+	raw     func(data []byte) interface{}                // Wraps encoded bytes so marshal embeds them as they are
+	split   func(data []byte) (map[string][]byte, error) // Splits an encoded object into its encoded fields
+	decode  func(data []byte) (models.Envelope, error)   // Decodes an inbound envelope, with a JSON payload
+	null    []byte                                       // Encoded null
+}
+
+// envelope is models.Envelope with a payload in either encoding
+type envelope struct {
+	Type    models.MessageType `json:"type"`
+	Seq     uint64             `json:"seq"`
+	Payload interface{}        `json:"payload,omitempty"`
+}
+
+// jsonCodec is the default text encoding
+var jsonCodec = &codec{
+	frame:   websocket.TextMessage,
+	marshal: json.Marshal,
+	raw:     func(data []byte) interface{} { return json.RawMessage(data) },
+	split: func(data []byte) (map[string][]byte, error) {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		split := make(map[string][]byte, len(fields))
+		for key, value := range fields {
+			split[key] = value
+		}
+		return split, nil
+	},
+	decode: func(data []byte) (models.Envelope, error) {
+		var env models.Envelope
+		err := json.Unmarshal(data, &env)
+		return env, err
+	},
+	null: []byte("null"),
+}
+
+// msgpackCodec is the compact binary encoding
+var msgpackCodec = &codec{
+	frame:   websocket.BinaryMessage,
+	marshal: marshalMsgpack,
+	raw:     func(data []byte) interface{} { return msgpack.RawMessage(data) },
+	split: func(data []byte) (map[string][]byte, error) {
+		var fields map[string]msgpack.RawMessage
+		if err := unmarshalMsgpack(data, &fields); err != nil {
+			return nil, err
+		}
+		split := make(map[string][]byte, len(fields))
+		for key, value := range fields {
+			split[key] = value
+		}
+		return split, nil
+	},
+	decode: decodeMsgpackEnvelope,
+	null:   []byte{0xc0},
+}
+
+// marshalMsgpack encodes v as MessagePack, naming fields after their JSON tags
+func marshalMsgpack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalMsgpack decodes MessagePack into v, matching fields by their JSON tags
+func unmarshalMsgpack(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// decodeMsgpackEnvelope decodes a binary envelope
+// The payload is converted to JSON so inbound messages are handled the same
+// way whatever encoding they arrived in; they are small, so this is cheap
+func decodeMsgpackEnvelope(data []byte) (models.Envelope, error) {
+	var in struct {
+		Type    models.MessageType `json:"type"`
+		Seq     uint64             `json:"seq"`
+		Payload msgpack.RawMessage `json:"payload"`
+	}
+	if err := unmarshalMsgpack(data, &in); err != nil {
+		return models.Envelope{}, err
+	}
+
+	env := models.Envelope{Type: in.Type, Seq: in.Seq}
+	if len(in.Payload) > 0 {
+		var payload interface{}
+		if err := unmarshalMsgpack(in.Payload, &payload); err != nil {
+			return env, err
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return env, err
+		}
+		env.Payload = body
+	}
+	return env, nil
+}
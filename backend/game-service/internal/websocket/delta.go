@@ -0,0 +1,166 @@
+package websocket
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// keyframeInterval is how many deltas are sent between full keyframes
+// Keyframes bound how long a client that silently went wrong stays wrong
+const keyframeInterval = 50
+
+// sharedState is a state sent to every peer of a session on one tick
+// It is encoded at most once per codec however many peers it goes to, and
+// peers in delta mode keep it as the base of their next delta, so each state
+// is encoded once for the keyframes and both sides of every diff
+type sharedState struct {
+	models.GameState
+	encoded map[*codec]*encodedState // Encodings made so far, by codec
+	mutex   sync.Mutex               // Mutex for encoded
+}
+
+// encodedState is a state encoded with one codec
+type encodedState struct {
+	whole  []byte            // The whole state
+	fields map[string][]byte // Its top-level fields except the snake bodies; read only
+}
+
+// newSharedState wraps a state that has not been encoded yet
+func newSharedState(state models.GameState) *sharedState {
+	return &sharedState{GameState: state, encoded: make(map[*codec]*encodedState)}
+}
+
+// encode returns the state encoded with c, encoding it on first use
+// The snake bodies are left out of the fields because diffState sends them separately
+func (s *sharedState) encode(c *codec) (*encodedState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if e, ok := s.encoded[c]; ok {
+		return e, nil
+	}
+	whole, err := c.marshal(s.GameState)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := c.split(whole)
+	if err != nil {
+		return nil, err
+	}
+	delete(fields, "snake")
+	delete(fields, "snakes")
+
+	e := &encodedState{whole: whole, fields: fields}
+	s.encoded[c] = e
+	return e, nil
+}
+
+// diffState returns the delta that turns prev into next
+// Snake bodies are sent as new heads and trimmed tails along with the details
+// that changed; every other field is sent whole, encoded with c, and only
+// when it changed
+func diffState(c *codec, prev, next *sharedState) (models.StateDelta, error) {
+	var delta models.StateDelta
+
+	if body := diffBody(prev.Snake, next.Snake); len(body.Head) > 0 || body.Trim > 0 {
+		delta.Snake = &body
+	}
+
+	old := make(map[string]models.SnakeState, len(prev.Snakes))
+	for _, snake := range prev.Snakes {
+		old[snake.Owner] = snake
+	}
+	for _, snake := range next.Snakes {
+		snake := snake // The delta points at the details
+		before, known := old[snake.Owner]
+		d := diffBody(before.Body, snake.Body)
+		d.Owner = snake.Owner
+		if !known || snake.Direction != before.Direction {
+			d.Direction = &snake.Direction
+		}
+		if !known || snake.Score != before.Score {
+			d.Score = &snake.Score
+		}
+		if !known || snake.Alive != before.Alive {
+			d.Alive = &snake.Alive
+		}
+		if !known || snake.Team != before.Team {
+			d.Team = &snake.Team
+		}
+		if !known || snake.Ack != before.Ack {
+			d.Ack = &snake.Ack
+		}
+		delta.Snakes = append(delta.Snakes, d)
+		delete(old, snake.Owner)
+	}
+	for owner := range old {
+		delta.Removed = append(delta.Removed, owner)
+	}
+	sort.Strings(delta.Removed)
+
+	fields, err := diffFields(c, prev, next)
+	if err != nil {
+		return delta, err
+	}
+	delta.Fields = fields
+	return delta, nil
+}
+
+// diffBody expresses next as new head segments in front of prev with its tail trimmed
+// Usually that is one new head and at most one trimmed segment. A body that
+// does not follow on from prev is sent as all head with all of prev trimmed
+func diffBody(prev, next []models.Point) models.SnakeDelta {
+	for added := 0; added < len(next); added++ {
+		kept := len(next) - added
+		if kept <= len(prev) && equalPoints(next[added:], prev[:kept]) {
+			return models.SnakeDelta{Head: next[:added], Trim: len(prev) - kept}
+		}
+	}
+	return models.SnakeDelta{Head: next, Trim: len(prev)}
+}
+
+// equalPoints reports whether a and b hold the same points in the same order
+func equalPoints(a, b []models.Point) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffFields returns the top-level fields other than snake bodies that differ
+// between prev and next, keyed by JSON name and encoded with c
+// Fields that next leaves out are sent as null
+func diffFields(c *codec, prev, next *sharedState) (map[string]interface{}, error) {
+	before, err := prev.encode(c)
+	if err != nil {
+		return nil, err
+	}
+	after, err := next.encode(c)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]interface{})
+	for key, value := range after.fields {
+		if !bytes.Equal(before.fields[key], value) {
+			changed[key] = c.raw(value)
+		}
+	}
+	for key := range before.fields {
+		if _, ok := after.fields[key]; !ok {
+			changed[key] = c.raw(c.null)
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	return changed, nil
+}
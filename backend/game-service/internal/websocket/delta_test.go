@@ -0,0 +1,273 @@
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/snake-game/game-service/internal/game"
+	"github.com/snake-game/game-service/pkg/models"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// applyBody rebuilds a body from its previous value and a snake delta
+// A body trimmed away entirely comes back nil, as a dead snake's is
+func applyBody(prev []models.Point, d models.SnakeDelta) []models.Point {
+	if len(d.Head) == 0 && d.Trim == len(prev) {
+		return nil
+	}
+	body := append([]models.Point{}, d.Head...)
+	return append(body, prev[:len(prev)-d.Trim]...)
+}
+
+// applyDelta rebuilds the next state the way a client would, with the
+// changed fields encoded by c
+func applyDelta(t *testing.T, c *codec, prev models.GameState, delta models.StateDelta) models.GameState {
+	t.Helper()
+
+	// Start from the previous fields and overwrite the ones that changed
+	encoded, err := newSharedState(prev).encode(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := make(map[string][]byte, len(encoded.fields))
+	for key, value := range encoded.fields {
+		fields[key] = value
+	}
+	for key, value := range delta.Fields {
+		switch raw := value.(type) {
+		case json.RawMessage:
+			fields[key] = raw
+		case msgpack.RawMessage:
+			fields[key] = raw
+		default:
+			t.Fatalf("Field %s is not encoded: %T", key, value)
+		}
+	}
+	object := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		object[key] = c.raw(value)
+	}
+	whole, err := c.marshal(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var next models.GameState
+	if c == msgpackCodec {
+		err = unmarshalMsgpack(whole, &next)
+	} else {
+		err = json.Unmarshal(whole, &next)
+	}
+	if err != nil {
+		t.Fatalf("Applying fields: %v", err)
+	}
+
+	next.Snake = prev.Snake
+	if delta.Snake != nil {
+		next.Snake = applyBody(prev.Snake, *delta.Snake)
+	}
+
+	// Details left out of a snake delta keep their previous values
+	old := make(map[string]models.SnakeState)
+	for _, snake := range prev.Snakes {
+		old[snake.Owner] = snake
+	}
+	for _, d := range delta.Snakes {
+		snake := old[d.Owner]
+		snake.Owner = d.Owner
+		snake.Body = applyBody(snake.Body, d)
+		if d.Direction != nil {
+			snake.Direction = *d.Direction
+		}
+		if d.Score != nil {
+			snake.Score = *d.Score
+		}
+		if d.Alive != nil {
+			snake.Alive = *d.Alive
+		}
+		if d.Team != nil {
+			snake.Team = *d.Team
+		}
+		if d.Ack != nil {
+			snake.Ack = *d.Ack
+		}
+		next.Snakes = append(next.Snakes, snake)
+	}
+	return next
+}
+
+// sameState compares states by their JSON encoding, which is what clients see
+func sameState(a, b models.GameState) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	var m, n interface{}
+	json.Unmarshal(x, &m)
+	json.Unmarshal(y, &n)
+	return reflect.DeepEqual(m, n)
+}
+
+func TestDiffBody(t *testing.T) {
+	prev := []models.Point{{X: 5, Y: 5}, {X: 4, Y: 5}, {X: 3, Y: 5}}
+
+	// Plain move: one new head, one trimmed tail
+	d := diffBody(prev, []models.Point{{X: 6, Y: 5}, {X: 5, Y: 5}, {X: 4, Y: 5}})
+	if len(d.Head) != 1 || d.Trim != 1 {
+		t.Errorf("Expected one head and one trim, got %+v", d)
+	}
+
+	// Growing keeps the tail
+	d = diffBody(prev, []models.Point{{X: 6, Y: 5}, {X: 5, Y: 5}, {X: 4, Y: 5}, {X: 3, Y: 5}})
+	if len(d.Head) != 1 || d.Trim != 0 {
+		t.Errorf("Expected one head and no trim, got %+v", d)
+	}
+
+	// An unrelated body replaces everything
+	next := []models.Point{{X: 10, Y: 10}}
+	d = diffBody(prev, next)
+	if !equalPoints(applyBody(prev, d), next) {
+		t.Errorf("Expected replacement body %v, got %v", next, applyBody(prev, d))
+	}
+}
+
+func TestDeltaRoundTrip(t *testing.T) {
+	for _, c := range []*codec{jsonCodec, msgpackCodec} {
+		g := game.NewGame(models.GameConfig{GridSize: 20, InitialX: 2, InitialY: 2, Seed: 3, FoodItems: 5})
+		prev := g.GetState()
+		for i := 0; i < 15; i++ {
+			g.Update()
+			next := g.GetState()
+
+			delta, err := diffState(c, newSharedState(prev), newSharedState(next))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := applyDelta(t, c, prev, delta); !sameState(got, next) {
+				t.Fatalf("Tick %d: delta does not rebuild the state\nwant %+v\ngot  %+v", i, next, got)
+			}
+			prev = next
+		}
+	}
+}
+
+func TestDeltaRoundTripArena(t *testing.T) {
+	arena := game.NewArena(models.GameConfig{GridSize: 20, Seed: 3, Teams: 2})
+	arena.Join("alice")
+	arena.Join("bob")
+	prev := arena.GetState()
+	for i := 0; i < 15; i++ {
+		if i == 5 {
+			arena.Leave("bob")
+		}
+		arena.Update()
+		next := arena.GetState()
+
+		delta, err := diffState(jsonCodec, newSharedState(prev), newSharedState(next))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := applyDelta(t, jsonCodec, prev, delta); !sameState(got, next) {
+			t.Fatalf("Tick %d: delta does not rebuild the arena\nwant %+v\ngot  %+v", i, next, got)
+		}
+		prev = next
+	}
+}
+
+func TestMsgpackState(t *testing.T) {
+	arena := game.NewArena(models.GameConfig{GridSize: 20, Seed: 3, Teams: 2, FoodItems: 3, ShrinkEvery: 2})
+	arena.Join("alice")
+	arena.Join("bob")
+	arena.Update()
+	state := arena.GetState()
+
+	encoded, err := msgpackCodec.marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded models.GameState
+	if err := unmarshalMsgpack(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !sameState(decoded, state) {
+		t.Errorf("MessagePack does not round trip the state\nwant %+v\ngot  %+v", state, decoded)
+	}
+
+	// Field names follow the JSON encoding
+	fields, err := msgpackCodec.split(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["snakes"]; !ok {
+		t.Errorf("Expected JSON field names, got %v", fields)
+	}
+
+	// Inbound payloads are handed on as JSON
+	env, _ := marshalMsgpack(map[string]interface{}{
+		"type":    models.MessageDirection,
+		"seq":     4,
+		"payload": map[string]string{"direction": string(models.Up)},
+	})
+	decodedEnv, err := decodeMsgpackEnvelope(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload models.DirectionPayload
+	if err := json.Unmarshal(decodedEnv.Payload, &payload); err != nil || payload.Direction != models.Up || decodedEnv.Seq != 4 {
+		t.Errorf("Unexpected envelope %+v (%v)", decodedEnv, err)
+	}
+}
+
+func TestSnakeDetailsChangeToZero(t *testing.T) {
+	prev := models.GameState{Snakes: []models.SnakeState{
+		{Owner: "alice", Body: []models.Point{{X: 2, Y: 2}}, Direction: models.Right, Score: 3, Alive: true, Team: "red"},
+	}}
+	next := prev
+	next.Snakes = []models.SnakeState{
+		{Owner: "alice", Body: []models.Point{{X: 2, Y: 2}}, Direction: models.Right, Score: 0, Alive: false, Team: "red"},
+	}
+
+	delta, err := diffState(jsonCodec, newSharedState(prev), newSharedState(next))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := delta.Snakes[0]
+	if d.Alive == nil || *d.Alive || d.Score == nil || *d.Score != 0 {
+		t.Errorf("Expected the snake to be sent as dead with no score, got %+v", d)
+	}
+	if d.Direction != nil || d.Team != nil || d.Ack != nil {
+		t.Errorf("Expected unchanged details to be left out, got %+v", d)
+	}
+
+	// The change survives the wire
+	encoded, _ := json.Marshal(delta)
+	var decoded models.StateDelta
+	json.Unmarshal(encoded, &decoded)
+	if got := applyDelta(t, jsonCodec, prev, decoded); !sameState(got, next) {
+		t.Errorf("Delta does not rebuild the state\nwant %+v\ngot  %+v", next, got)
+	}
+}
+
+func TestStateEncodedOnce(t *testing.T) {
+	marshals := 0
+	counting := *jsonCodec
+	counting.marshal = func(v interface{}) ([]byte, error) {
+		marshals++
+		return json.Marshal(v)
+	}
+
+	g := game.NewGame(models.GameConfig{GridSize: 20, InitialX: 2, InitialY: 2, Seed: 3})
+	prev := newSharedState(g.GetState())
+	for i := 0; i < 5; i++ {
+		g.Update()
+		next := newSharedState(g.GetState())
+		for peer := 0; peer < 3; peer++ {
+			if _, err := diffState(&counting, prev, next); err != nil {
+				t.Fatal(err)
+			}
+		}
+		prev = next
+	}
+	if marshals != 6 {
+		t.Errorf("Expected each of the 6 states to be encoded once, got %d encodings", marshals)
+	}
+}
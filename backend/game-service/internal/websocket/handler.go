@@ -1,122 +1,620 @@
 package websocket
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/snake-game/game-service/internal/game"
+	"github.com/snake-game/game-service/internal/scheduler"
 	"github.com/snake-game/game-service/pkg/models"
 )
 
 // Handler manages WebSocket connections and game state
-// It maintains a map of active connections to their respective game instances
-// and handles the lifecycle of each game session
+// It maintains a map of active connections to the game sessions they play in
+// and handles the lifecycle of each session. A session is either a solo game
+// or a shared arena with several connections, and can be watched by spectators.
+// A solo game whose player drops stays paused for a grace period so the player
+// can reconnect to it with its resume token. Games are ticked by a scheduler
+// that spreads the sessions across a fixed pool of worker loops
 type Handler struct {
-	clients    map[*websocket.Conn]*game.Game // Maps each connection to its game instance
-	register   chan *websocket.Conn           // Channel for new client registrations
-	unregister chan *websocket.Conn           // Channel for client disconnections
-	mutex      sync.RWMutex                   // Mutex for thread-safe access to clients map
-	config     models.GameConfig              // Game configuration shared by all instances
+	clients    map[*Peer]*client    // Maps each connection to its client
+	sessions   map[*session]bool    // Every running session, solo games and arenas alike
+	arenas     map[string]*session  // Shared arena sessions by name
+	resumable  map[string]*session  // Solo sessions by resume token
+	register   chan registration    // Channel for new client registrations
+	unregister chan *Peer           // Channel for client disconnections
+	restart    chan *Peer           // Channel for restart requests on existing connections
+	mutex      sync.RWMutex         // Mutex for thread-safe access to the maps
+	config     models.GameConfig    // Game configuration shared by all instances
+	scheduler  *scheduler.Scheduler // Worker loops ticking every session's game
+	arenaEnded ArenaEnded           // Called once an arena finishes or empties; nil if nobody listens
+}
+
+// runner is a game that advances on the handler's scheduler
+// Both solo games and shared arenas implement it
+type runner interface {
+	ID() string
+	Update()
+	TickInterval() time.Duration
+	GetState() models.GameState
+}
+
+// session is a running game together with the connections it broadcasts to
+// Each game picks its own tick interval, so sessions advance independently
+type session struct {
+	game    runner         // The solo game or arena being played
+	conns   map[*Peer]bool // Connections that receive the session's state
+	job     *scheduler.Job // Scheduled ticks of the game; nil until the session is added
+	arena   string         // Arena name; empty for solo games
+	players int            // Connections controlling a snake; the rest are spectators
+	token   string         // Token the solo game's player resumes with; empty if resuming is off
+	grace   time.Duration  // How long the game waits for its player after the connection drops
+	dropped time.Time      // When the player's connection dropped; zero while connected
+	expiry  *time.Timer    // Closes the game if its player does not reconnect in time
+	ended   bool           // True once the arena has been reported as ended
+}
+
+// client is a connection together with the session it plays in or watches
+type client struct {
+	session   *session // Session the connection belongs to
+	player    string   // Owner of the client's snake in an arena; empty for solo games
+	spectator bool     // True if the connection only watches the session
+}
+
+// defaultResumeGrace is how long a dropped solo game waits for its player
+// when the configuration does not say
+const defaultResumeGrace = 30 * time.Second
+
+// idleCheckInterval is how often Run looks for players who stopped sending input
+const idleCheckInterval = time.Second
+
+// registration describes a new connection and the game it wants to play
+type registration struct {
+	conn     *Peer
+	config   models.GameConfig
+	arena    string // Arena to join; empty for a solo game
+	player   string // Player name inside the arena
+	spectate string // Game ID or arena name to watch; empty to play
+	resume   string // Resume token of the solo game to reattach to; empty to play a new one
 }
 
 // NewHandler creates a new WebSocket handler
 // It initializes the channels and maps needed for connection management
 func NewHandler(config models.GameConfig) *Handler {
 	return &Handler{
-		clients:    make(map[*websocket.Conn]*game.Game), // Initialize empty clients map
-		register:   make(chan *websocket.Conn),           // Channel for handling new connections
-		unregister: make(chan *websocket.Conn),           // Channel for handling disconnections
-		config:     config,                               // Store shared game configuration
+		clients:    make(map[*Peer]*client),   // Initialize empty clients map
+		sessions:   make(map[*session]bool),   // Initialize empty sessions set
+		arenas:     make(map[string]*session), // Initialize empty arenas map
+		resumable:  make(map[string]*session), // Initialize empty resume tokens map
+		register:   make(chan registration),   // Channel for handling new connections
+		unregister: make(chan *Peer),          // Channel for handling disconnections
+		restart:    make(chan *Peer),          // Channel for handling restarts
+		config:     config,                    // Store shared game configuration
+		scheduler:  scheduler.New(config.Workers),
 	}
 }
 
+// Register schedules a new connection to be added with its own game
+// The config usually starts from the shared one with per-connection overrides such as a seed
+func (h *Handler) Register(conn *Peer, config models.GameConfig) {
+	h.register <- registration{conn: conn, config: config}
+}
+
+// RegisterArena schedules a new connection to join the named shared arena
+// The arena is created with the given configuration when its first player arrives;
+// later players share the arena's existing configuration
+func (h *Handler) RegisterArena(conn *Peer, arena, player string, config models.GameConfig) {
+	h.register <- registration{conn: conn, config: config, arena: arena, player: player}
+}
+
+// ArenaEnded is called with an arena's name once the arena finishes or its
+// last player leaves, whichever comes first, and whether it had finished by then
+type ArenaEnded func(arena string, finished bool)
+
+// OnArenaEnded sets the function called when an arena ends
+// It must be set before Run. It is called with the handler's lock held, so it
+// must not call back into the handler
+func (h *Handler) OnArenaEnded(f ArenaEnded) {
+	h.arenaEnded = f
+}
+
+// ArenaState returns the current state of the named arena
+// It returns false while nobody is connected to the arena
+func (h *Handler) ArenaState(arena string) (models.GameState, bool) {
+	h.mutex.RLock()
+	s, ok := h.arenas[arena]
+	h.mutex.RUnlock()
+	if !ok {
+		return models.GameState{}, false
+	}
+	return s.game.GetState(), true
+}
+
+// RegisterSpectator schedules a new connection to watch an existing session
+// The session is found by its game ID or, for arenas, by the arena's name
+func (h *Handler) RegisterSpectator(conn *Peer, session string) {
+	h.register <- registration{conn: conn, spectate: session}
+}
+
+// Resume schedules a new connection to reattach to a dropped solo game
+// The game is found by the resume token sent in the first state of the old connection
+func (h *Handler) Resume(conn *Peer, token string) {
+	h.register <- registration{conn: conn, resume: token}
+}
+
+// Unregister schedules a connection to be removed and closed
+func (h *Handler) Unregister(conn *Peer) {
+	h.unregister <- conn
+}
+
 // Run starts the WebSocket handler's main loop
 // This method runs in its own goroutine and handles:
 // - New client connections
 // - Client disconnections
-// - Regular game state updates
+// - Disconnecting idle players
+// It also starts the scheduler, whose workers update the games and send
+// their states
 func (h *Handler) Run() {
-	ticker := time.NewTicker(time.Millisecond * time.Duration(h.config.Speed))
-	defer ticker.Stop()
+	h.scheduler.Start()
+	idle := time.NewTicker(idleCheckInterval)
+	defer idle.Stop()
 
 	for {
 		select {
-		case client := <-h.register:
-			h.handleRegister(client)
+		case reg := <-h.register:
+			h.handleRegister(reg)
 		case client := <-h.unregister:
 			h.handleUnregister(client)
-		case <-ticker.C:
-			h.updateGames() // Update all active games on each tick
+		case client := <-h.restart:
+			h.handleRestart(client)
+		case now := <-idle.C:
+			h.disconnectIdle(now)
 		}
 	}
 }
 
+// newSession wraps a game in a session
+func newSession(g runner) *session {
+	return &session{
+		game:  g,
+		conns: make(map[*Peer]bool),
+	}
+}
+
+// addSession adds a session and schedules its game's first tick
+// Callers must hold the mutex
+func (h *Handler) addSession(s *session) {
+	h.sessions[s] = true
+	tick := scheduler.TaskFunc(func(time.Time) time.Duration { return h.tick(s) })
+	s.job = h.scheduler.Add(tick, s.game.TickInterval())
+}
+
+// SchedulerStats reports how late the games' ticks are starting
+func (h *Handler) SchedulerStats() models.SchedulerStats {
+	return h.scheduler.Stats()
+}
+
+// resumeGrace returns how long a solo game with the given configuration
+// waits for its player to reconnect; zero if it does not wait
+func resumeGrace(config models.GameConfig) time.Duration {
+	switch {
+	case config.ResumeGrace < 0:
+		return 0
+	case config.ResumeGrace == 0:
+		return defaultResumeGrace
+	}
+	return time.Duration(config.ResumeGrace) * time.Second
+}
+
+// newResumeToken returns a random token that is hard to guess
+func newResumeToken() string {
+	b := make([]byte, 16)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 // handleRegister registers a new WebSocket connection
-// Creates a new game instance for the client and adds it to the active clients map
-func (h *Handler) handleRegister(conn *websocket.Conn) {
+// Solo clients get a new game instance and a resume token; arena clients join
+// the named arena, which is created on first use and again once it has finished
+func (h *Handler) handleRegister(reg registration) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
-	// Create new game instance for client with shared configuration
-	h.clients[conn] = game.NewGame(h.config)
+	if reg.conn.closed() {
+		return // Unregistered before the registration was handled
+	}
+	if reg.spectate != "" {
+		h.handleSpectate(reg)
+		return
+	}
+	if reg.resume != "" {
+		h.handleResume(reg)
+		return
+	}
+
+	var s *session
+	if reg.arena == "" {
+		// Create new game instance for client with its requested configuration
+		s = newSession(game.NewGame(reg.config))
+		if s.grace = resumeGrace(reg.config); s.grace > 0 {
+			s.token = newResumeToken()
+			h.resumable[s.token] = s
+			reg.conn.SetResumeToken(s.token)
+		}
+	} else {
+		// A finished arena stays with its players until they leave, while
+		// newcomers start a fresh one under the same name
+		var ok bool
+		if s, ok = h.arenas[reg.arena]; !ok || s.game.(*game.Arena).Finished() {
+			s = newSession(game.NewArena(reg.config))
+			s.arena = reg.arena
+		}
+		if err := s.game.(*game.Arena).Join(reg.player); err != nil {
+			log.Printf("Player %s could not join arena %s: %v", reg.player, reg.arena, err)
+			reg.conn.Reject(err)
+			return
+		}
+		h.arenas[reg.arena] = s
+	}
+
+	s.conns[reg.conn] = true
+	s.players++
+	if s.job == nil {
+		h.addSession(s)
+	}
+	h.clients[reg.conn] = &client{session: s, player: reg.player}
 	log.Printf("Client connected. Total clients: %d", len(h.clients))
 }
 
-// handleUnregister removes a WebSocket connection
-// Cleans up the game instance and removes the client from the active clients map
-func (h *Handler) handleUnregister(conn *websocket.Conn) {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
+// handleSpectate adds a spectator to an existing session
+// Callers must hold the mutex
+func (h *Handler) handleSpectate(reg registration) {
+	s := h.findSession(reg.spectate)
+	if s == nil {
+		log.Printf("Spectator asked for unknown session %s", reg.spectate)
+		reg.conn.Reject(fmt.Errorf("session %q not found", reg.spectate))
+		return
+	}
 
-	if _, ok := h.clients[conn]; ok {
-		delete(h.clients, conn) // Remove client from active games
-		conn.Close()            // Close the WebSocket connection
-		log.Printf("Client disconnected. Total clients: %d", len(h.clients))
+	s.conns[reg.conn] = true
+	h.clients[reg.conn] = &client{session: s, spectator: true}
+	log.Printf("Spectator connected to %s. Total clients: %d", reg.spectate, len(h.clients))
+}
+
+// handleResume reattaches a player to their solo game
+// The game stays paused until the player resumes it. A connection still
+// attached to the game is replaced, since it is usually one whose drop the
+// server has not noticed yet
+// Callers must hold the mutex
+func (h *Handler) handleResume(reg registration) {
+	s, ok := h.resumable[reg.resume]
+	if !ok {
+		log.Printf("Client asked to resume an unknown session")
+		reg.conn.Reject(errors.New("unknown or expired resume token"))
+		return
+	}
+
+	for conn := range s.conns {
+		if !h.clients[conn].spectator {
+			delete(h.clients, conn)
+			delete(s.conns, conn)
+			s.players--
+			conn.Disconnect(ReasonReplaced)
+		}
 	}
+
+	s.conns[reg.conn] = true
+	s.players++
+	s.dropped = time.Time{}
+	if s.expiry != nil {
+		s.expiry.Stop()
+	}
+	h.clients[reg.conn] = &client{session: s}
+	reg.conn.SetResumeToken(s.token)
+	log.Printf("Client resumed game %s. Total clients: %d", s.game.ID(), len(h.clients))
 }
 
-// updateGames updates all active games and sends their states to clients
-// This is called on each game tick to advance the game state
-func (h *Handler) updateGames() {
+// findSession looks up a session by arena name or game ID
+// Callers must hold the mutex
+func (h *Handler) findSession(id string) *session {
+	if s, ok := h.arenas[id]; ok {
+		return s
+	}
+	for s := range h.sessions {
+		if s.game.ID() == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// Sessions describes every running session, sorted by ID
+func (h *Handler) Sessions() []models.SessionInfo {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
-	for conn, game := range h.clients {
-		game.Update() // Update game state
+	infos := make([]models.SessionInfo, 0, len(h.sessions))
+	for s := range h.sessions {
+		state := s.game.GetState()
+		info := models.SessionInfo{
+			ID:         state.ID,
+			Arena:      s.arena,
+			Players:    s.players,
+			Spectators: len(s.conns) - s.players,
+			Score:      state.Score,
+			Status:     state.Status,
+			GameOver:   state.GameOver,
+		}
+		for _, snake := range state.Snakes {
+			if snake.Score > info.Score {
+				info.Score = snake.Score
+			}
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
 
-		// Send updated state to client
-		if err := conn.WriteJSON(game.GetState()); err != nil {
+// handleUnregister removes and closes a WebSocket connection
+// The client's snake leaves its arena, and a session is cleaned up once its
+// last player is gone. A solo game that can be resumed is paused instead and
+// only cleaned up if its player has not reconnected when the grace period ends
+func (h *Handler) handleUnregister(conn *Peer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	c, ok := h.clients[conn]
+	if !ok {
+		// Not registered yet, as with a matched player whose registration is
+		// still on its way; closing the connection makes handleRegister drop it
+		conn.Close()
+		return
+	}
+
+	delete(h.clients, conn) // Remove client from active games
+	delete(c.session.conns, conn)
+	if !c.spectator {
+		c.session.players--
+		switch g := c.session.game.(type) {
+		case *game.Arena:
+			g.Leave(c.player)
+		case *game.Game:
+			if c.session.token != "" {
+				g.Pause()
+				s := c.session
+				s.dropped = time.Now()
+				s.expiry = time.AfterFunc(s.grace, func() { h.expireSession(s) })
+				log.Printf("Game %s paused for %s while its player reconnects", g.ID(), c.session.grace)
+			}
+		}
+	}
+	if c.session.players == 0 && c.session.dropped.IsZero() {
+		h.closeSession(c.session)
+	}
+
+	conn.Close() // Close the WebSocket connection
+	log.Printf("Client disconnected (%s). Total clients: %d", conn.Reason(), len(h.clients))
+}
+
+// closeSession removes a session and disconnects any spectators still watching it
+// Callers must hold the mutex
+func (h *Handler) closeSession(s *session) {
+	for spectator := range s.conns {
+		delete(h.clients, spectator)
+		spectator.Disconnect(ReasonSessionEnded)
+	}
+	delete(h.sessions, s)
+	h.scheduler.Remove(s.job)
+	if s.expiry != nil {
+		s.expiry.Stop()
+	}
+	if s.arena != "" {
+		if h.arenas[s.arena] == s {
+			delete(h.arenas, s.arena) // Unless a fresh arena has taken over the name
+		}
+		h.endArena(s)
+	}
+	if s.token != "" {
+		delete(h.resumable, s.token)
+	}
+}
+
+// endArena reports an arena session as ended the first time it is called
+// Callers must hold the mutex; tick holds it for reading, which is enough
+// because only the session's own tick and closeSession get here
+func (h *Handler) endArena(s *session) {
+	if s.ended {
+		return
+	}
+	s.ended = true
+	if h.arenaEnded != nil {
+		h.arenaEnded(s.arena, s.game.(*game.Arena).Finished())
+	}
+}
+
+// expireSession closes a solo game whose player did not reconnect in time
+// The player may have come back, or come back and dropped again, while the
+// timer was firing, so the drop is checked again under the lock
+func (h *Handler) expireSession(s *session) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.sessions[s] && !s.dropped.IsZero() && time.Since(s.dropped) >= s.grace {
+		log.Printf("Game %s closed; its player did not reconnect", s.game.ID())
+		h.closeSession(s)
+	}
+}
+
+// handleRestart starts a new game on an existing connection
+// The client keeps its entry in the clients map; its game is reset in place
+// and the fresh state, with its new game ID, is sent straight away to the
+// player and any spectators. Only solo games can be restarted. The write lock
+// keeps the session's worker from sending a state of the old game after it
+func (h *Handler) handleRestart(conn *Peer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	c, ok := h.clients[conn]
+	if !ok {
+		return // Disconnected before the restart was handled
+	}
+	g, ok := c.session.game.(*game.Game)
+	if !ok {
+		return
+	}
+
+	g.Reset()
+	h.scheduler.Reschedule(c.session.job, g.TickInterval())
+
+	state := newSharedState(g.GetState())
+	log.Printf("Game restarted with ID %s", state.ID)
+	for conn := range c.session.conns {
+		if err := conn.sendShared(state); err != nil {
 			log.Printf("Error sending state to client: %v", err)
-			h.unregister <- conn // Schedule client for disconnection on error
 		}
 	}
 }
 
-// HandleDirection processes a direction change request from a client
-// Validates and applies the direction change to the appropriate game instance
-func (h *Handler) HandleDirection(conn *websocket.Conn, msg []byte) error {
+// disconnectIdle disconnects players who have sent nothing for longer than
+// their idle timeout. Spectators are not expected to send anything and are left
+// alone, as are players who paused their solo game and may come back to it
+// later. The connections are only closed here; their read loops unregister them
+func (h *Handler) disconnectIdle(now time.Time) {
+	h.mutex.RLock()
+	var idle []*Peer
+	for conn, c := range h.clients {
+		if c.spectator {
+			continue
+		}
+		if g, ok := c.session.game.(*game.Game); ok && g.Paused() {
+			continue
+		}
+		if conn.Idle(now) {
+			idle = append(idle, conn)
+		}
+	}
+	h.mutex.RUnlock()
+
+	for _, conn := range idle {
+		log.Printf("Disconnecting idle client")
+		conn.Disconnect(ReasonIdle)
+	}
+}
+
+// tick updates a session's game and sends its state to clients
+// It runs on one of the scheduler's workers and returns when the game is next
+// due: its own tick interval, which shrinks as the player levels up and
+// changes with power-ups. Arenas broadcast one state to every connection in
+// them. States are only queued for each peer's writer, so a slow client cannot
+// hold up the lock or anyone else's game; peers that fall too far behind
+// disconnect themselves and are unregistered by their read loops
+func (h *Handler) tick(s *session) time.Duration {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
-	// Find the game instance for this connection
-	game, exists := h.clients[conn]
+	if !h.sessions[s] {
+		return 0 // Closed while the tick was starting
+	}
+
+	s.game.Update() // Update game state
+
+	// Send updated state to every client in the session, encoded once for all of them
+	state := newSharedState(s.game.GetState())
+	for conn := range s.conns {
+		if err := conn.sendShared(state); err != nil {
+			log.Printf("Error sending state to client: %v", err)
+		}
+	}
+	if s.arena != "" && state.GameOver {
+		h.endArena(s)
+	}
+	return s.game.TickInterval()
+}
+
+// HandleMessage processes a message from a client
+// Direction changes and pause/resume commands are applied to the client's game instance;
+// restarts are handed to Run, which owns the session. Arena clients can only
+// change the direction of their own snake. Errors are also reported to
+// clients that use the envelope protocol
+func (h *Handler) HandleMessage(conn *Peer, msg []byte) error {
+	env, err := conn.Decode(msg)
+	if err == nil {
+		err = h.dispatch(conn, env)
+	}
+	if err != nil {
+		conn.SendError(err, env.Seq)
+	}
+	return err
+}
+
+// dispatch applies a decoded message to the client's session
+func (h *Handler) dispatch(conn *Peer, env models.Envelope) error {
+	if env.Type == models.MessageResync {
+		conn.RequestKeyframe() // Spectators can resync too
+		return nil
+	}
+
+	// Find the game session for this connection
+	// The lock is released before acting so a restart cannot block Run
+	h.mutex.RLock()
+	c, exists := h.clients[conn]
+	h.mutex.RUnlock()
 	if !exists {
 		return fmt.Errorf("no game found for connection")
 	}
 
-	// Parse the direction from the message
-	var dir struct {
-		Direction string `json:"direction"`
+	if c.spectator {
+		return nil // Spectators only watch; anything they send is ignored
 	}
-	if err := json.Unmarshal(msg, &dir); err != nil {
-		return fmt.Errorf("invalid direction message: %v", err)
+
+	switch env.Type {
+	case models.MessageDirection:
+		var p models.DirectionPayload
+		if err := json.Unmarshal(env.Payload, &p); err != nil {
+			return fmt.Errorf("invalid direction: %v", err)
+		}
+		switch g := c.session.game.(type) {
+		case *game.Game:
+			g.SetDirection(p.Direction, p.Seq)
+		case *game.Arena:
+			// Arenas are shared, so players can only steer their own snake
+			return g.SetDirection(c.player, p.Direction, p.Seq)
+		}
+	case models.MessageCommand:
+		var p models.CommandPayload
+		if err := json.Unmarshal(env.Payload, &p); err != nil {
+			return fmt.Errorf("invalid command: %v", err)
+		}
+		g, ok := c.session.game.(*game.Game)
+		if !ok {
+			return fmt.Errorf("command %q is not available in an arena", p.Command)
+		}
+		return h.handleCommand(conn, g, p.Command)
+	default:
+		return fmt.Errorf("unknown message type %q", env.Type)
 	}
+	return nil
+}
 
-	// Update the game's direction
-	game.SetDirection(models.Direction(dir.Direction))
+// handleCommand applies a command to a solo game
+func (h *Handler) handleCommand(conn *Peer, g *game.Game, command models.Command) error {
+	switch command {
+	case models.CommandPause:
+		g.Pause()
+	case models.CommandResume:
+		g.Resume()
+	case models.CommandRestart:
+		h.restart <- conn
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
 	return nil
 }
@@ -0,0 +1,29 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+func TestRegisterAfterUnregister(t *testing.T) {
+	config := models.GameConfig{GridSize: 20, Speed: 50, Seed: 1}
+	h := NewHandler(config)
+	go h.Run()
+	p, _ := peerPair(t, models.ProtocolV1, SendQueue{Size: 8})
+	go p.writeLoop()
+
+	// The read loop ended before the matchmaker's registration arrived
+	h.Unregister(p)
+	h.RegisterArena(p, "match/1", "alice", config)
+
+	h.Unregister(p) // Run only takes this once it has handled the registration
+	if sessions := h.Sessions(); len(sessions) != 0 {
+		t.Errorf("Expected a disconnected peer not to be registered, got %+v", sessions)
+	}
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if len(h.clients) != 0 {
+		t.Errorf("Expected no clients, got %d", len(h.clients))
+	}
+}
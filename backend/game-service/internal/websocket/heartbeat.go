@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Heartbeat configures how a peer's connection is checked for liveness
+// A zero field turns that check off
+type Heartbeat struct {
+	PingInterval time.Duration // How often a ping is sent to the client
+	PongWait     time.Duration // How long the connection may stay silent, pongs included, before it is considered dead
+	WriteWait    time.Duration // How long a single write may take
+	IdleTimeout  time.Duration // How long a player may go without sending anything
+}
+
+// DisconnectReason records why a connection ended
+type DisconnectReason string
+
+// Reasons a connection can end
+const (
+	ReasonClosed       DisconnectReason = "closed"            // The client closed the connection
+	ReasonTimeout      DisconnectReason = "heartbeat timeout" // Nothing, not even a pong, arrived within PongWait
+	ReasonReadError    DisconnectReason = "read error"        // Reading from the connection failed
+	ReasonWriteError   DisconnectReason = "write error"       // A write or ping failed or took longer than WriteWait
+	ReasonIdle         DisconnectReason = "idle"              // The player sent nothing for IdleTimeout
+	ReasonRejected     DisconnectReason = "rejected"          // The server refused the connection
+	ReasonReplaced     DisconnectReason = "replaced"          // A resumed connection took over the player's game
+	ReasonSessionEnded DisconnectReason = "session ended"     // The session being watched ended
+)
+
+// startHeartbeat sets the read deadline and starts pinging the client
+func (p *Peer) startHeartbeat() {
+	p.extendRead()
+	p.conn.SetPongHandler(func(string) error {
+		p.extendRead()
+		return nil
+	})
+	if p.heartbeat.PingInterval > 0 {
+		go p.ping()
+	}
+}
+
+// extendRead pushes the read deadline back after hearing from the client
+func (p *Peer) extendRead() {
+	if p.heartbeat.PongWait > 0 {
+		p.conn.SetReadDeadline(time.Now().Add(p.heartbeat.PongWait))
+	}
+}
+
+// writeDeadline returns the deadline for a write starting now
+// The zero time means no deadline
+func (p *Peer) writeDeadline() time.Time {
+	if p.heartbeat.WriteWait <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(p.heartbeat.WriteWait)
+}
+
+// ping sends pings until the connection is closed
+// Control frames may be written alongside other writes, so no lock is needed
+func (p *Peer) ping() {
+	ticker := time.NewTicker(p.heartbeat.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.conn.WriteControl(websocket.PingMessage, nil, p.writeDeadline()); err != nil {
+				p.setReason(ReasonWriteError)
+				p.Close()
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Idle reports whether the client has sent nothing for longer than the idle timeout
+func (p *Peer) Idle(now time.Time) bool {
+	p.statusMutex.Lock()
+	defer p.statusMutex.Unlock()
+	return p.heartbeat.IdleTimeout > 0 && now.Sub(p.lastInput) > p.heartbeat.IdleTimeout
+}
+
+// setReason records why the connection ended, unless a reason is already known
+func (p *Peer) setReason(reason DisconnectReason) {
+	p.statusMutex.Lock()
+	defer p.statusMutex.Unlock()
+	if p.reason == "" {
+		p.reason = reason
+	}
+}
+
+// Reason returns why the connection ended, or an empty reason while it is open
+func (p *Peer) Reason() DisconnectReason {
+	p.statusMutex.Lock()
+	defer p.statusMutex.Unlock()
+	return p.reason
+}
+
+// readReason classifies the error that ended a read
+func readReason(err error) DisconnectReason {
+	var closeErr *websocket.CloseError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &closeErr):
+		return ReasonClosed
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ReasonTimeout
+	}
+	return ReasonReadError
+}
+
+// Disconnect closes the connection, telling the client why
+// The close frame follows whatever is already queued and is written by the
+// peer's writer, so Disconnect never waits on the network and is safe to call
+// with the handler's lock held
+func (p *Peer) Disconnect(reason DisconnectReason) {
+	p.setReason(reason)
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, string(reason))
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.enqueue(outbound{close: msg})
+}
@@ -0,0 +1,281 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// Subprotocols lists the subprotocols the server accepts during the upgrade
+var Subprotocols = []string{
+	models.ProtocolV1,
+	models.ProtocolV1Delta,
+	models.ProtocolV1MsgPack,
+	models.ProtocolV1DeltaMsgPack,
+}
+
+// protocol describes how a peer talks, as agreed through the subprotocol
+type protocol struct {
+	envelope bool   // True if messages are wrapped in envelopes
+	deltas   bool   // True if states are sent as keyframes and deltas
+	codec    *codec // Encoding of envelopes and payloads
+}
+
+// protocols maps every accepted subprotocol to how its peers talk
+// A peer that agreed none uses the original bare JSON messages
+var protocols = map[string]protocol{
+	"":                            {codec: jsonCodec},
+	models.ProtocolV1:             {envelope: true, codec: jsonCodec},
+	models.ProtocolV1Delta:        {envelope: true, deltas: true, codec: jsonCodec},
+	models.ProtocolV1MsgPack:      {envelope: true, codec: msgpackCodec},
+	models.ProtocolV1DeltaMsgPack: {envelope: true, deltas: true, codec: msgpackCodec},
+}
+
+// Peer is a WebSocket connection together with the protocol agreed for it
+// Connections that negotiated one of the envelope subprotocols exchange
+// envelopes; the rest use the original bare messages so today's frontend
+// keeps working. Messages are queued for the peer's own writer, so sending
+// never waits on the network and several goroutines can send to the same
+// peer. Its heartbeat notices dead connections and the reason each
+// connection ended is recorded
+type Peer struct {
+	conn        *websocket.Conn  // Underlying WebSocket connection
+	protocol                     // How the peer talks
+	seq         uint64           // Sequence number of the last message written; only the writer touches it
+	last        *sharedState     // Last state queued; in delta mode nil forces a keyframe
+	deltaRun    int              // Deltas queued since the last keyframe
+	resume      string           // Resume token to add to the next state sent; empty once sent
+	queue       chan outbound    // Messages waiting for the writer
+	sendQueue   SendQueue        // Queue size and slow consumer policy
+	mutex       sync.Mutex       // Mutex serializing sends so states and deltas queue in order
+	heartbeat   Heartbeat        // Deadlines and keep-alive settings
+	lastInput   time.Time        // When the client last sent a message
+	reason      DisconnectReason // Why the connection ended; the first reason recorded wins
+	statusMutex sync.Mutex       // Mutex for lastInput and reason, which are read while writes block
+	done        chan struct{}    // Closed with the connection to stop the pinger and writer
+	closeOnce   sync.Once        // Makes Close safe to call more than once
+}
+
+// NewPeer wraps an upgraded connection and starts its heartbeat and writer
+// The protocol follows the subprotocol agreed during the upgrade
+func NewPeer(conn *websocket.Conn, heartbeat Heartbeat, sendQueue SendQueue) *Peer {
+	p := newPeer(conn, heartbeat, sendQueue)
+	p.startHeartbeat()
+	go p.writeLoop()
+	return p
+}
+
+// newPeer wraps a connection without starting anything
+func newPeer(conn *websocket.Conn, heartbeat Heartbeat, sendQueue SendQueue) *Peer {
+	return &Peer{
+		conn:      conn,
+		protocol:  protocols[conn.Subprotocol()],
+		queue:     make(chan outbound, max(sendQueue.Size, 1)),
+		sendQueue: sendQueue,
+		heartbeat: heartbeat,
+		lastInput: time.Now(),
+		done:      make(chan struct{}),
+	}
+}
+
+// Send queues a message for the peer
+// In compatibility mode the payload is written bare and errors, which the
+// original protocol has no room for, are dropped
+func (p *Peer) Send(t models.MessageType, payload interface{}) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.envelope && t == models.MessageError {
+		return nil
+	}
+	return p.enqueue(outbound{t: t, payload: payload})
+}
+
+// SendState queues a game state for the peer
+func (p *Peer) SendState(state models.GameState) error {
+	return p.sendShared(newSharedState(state))
+}
+
+// sendShared queues a state that may also go to the session's other peers
+// In delta mode a keyframe is sent first, after keyframeInterval deltas, when
+// the game changes and whenever the client asks for one; otherwise only the
+// changes since the last state are sent. A state dropped because the queue
+// is full makes the next one a keyframe
+func (p *Peer) sendShared(state *sharedState) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// The token goes out once, so it stays out of the states deltas are built from
+	token := p.resume
+	p.resume = ""
+
+	var err error
+	switch {
+	case !p.deltas:
+		err = p.sendKeyframe(state, token)
+	case p.last == nil || p.last.ID != state.ID || p.deltaRun >= keyframeInterval || token != "":
+		err = p.sendKeyframe(state, token)
+		p.deltaRun = 0
+	default:
+		var delta models.StateDelta
+		if delta, err = diffState(p.codec, p.last, state); err == nil {
+			err = p.enqueue(outbound{t: models.MessageDelta, payload: delta})
+			p.deltaRun++
+		}
+	}
+
+	p.last = state
+	if err != nil {
+		// The client's copy is unknown, so start again from a keyframe, and
+		// try the token again with the next state
+		p.last = nil
+		p.resume = token
+	}
+	if err == errDropped {
+		return nil
+	}
+	return err
+}
+
+// sendKeyframe queues a whole state, reusing its shared encoding unless a
+// resume token has to be added to it
+// Callers must hold the mutex
+func (p *Peer) sendKeyframe(state *sharedState, token string) error {
+	if token != "" {
+		sent := state.GameState
+		sent.ResumeToken = token
+		return p.enqueue(outbound{t: models.MessageState, payload: sent})
+	}
+
+	encoded, err := state.encode(p.codec)
+	if err != nil {
+		return err
+	}
+	return p.enqueue(outbound{t: models.MessageState, payload: p.codec.raw(encoded.whole)})
+}
+
+// RequestKeyframe makes the next state sent to the peer a keyframe
+// Clients ask for one when they notice a gap in the sequence numbers
+func (p *Peer) RequestKeyframe() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.last = nil
+}
+
+// SetResumeToken adds a resume token to the next state sent to the peer
+func (p *Peer) SetResumeToken(token string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.resume = token
+}
+
+// write sends a message, wrapping it in an envelope if the peer uses them
+// A write that fails or misses its deadline ends the connection
+// Only the writer calls it
+func (p *Peer) write(t models.MessageType, payload interface{}) error {
+	err := p.encode(t, payload)
+	if err != nil {
+		p.setReason(ReasonWriteError)
+	}
+	return err
+}
+
+// encode encodes a message in the peer's protocol and writes it
+// Only the writer calls it
+func (p *Peer) encode(t models.MessageType, payload interface{}) error {
+	p.conn.SetWriteDeadline(p.writeDeadline())
+	if !p.envelope {
+		return p.conn.WriteJSON(payload)
+	}
+
+	body, err := p.codec.marshal(payload)
+	if err != nil {
+		return err
+	}
+	p.seq++
+	data, err := p.codec.marshal(envelope{Type: t, Seq: p.seq, Payload: p.codec.raw(body)})
+	if err != nil {
+		return err
+	}
+	return p.conn.WriteMessage(p.codec.frame, data)
+}
+
+// SendError reports an error to the peer, referring to the message that caused it
+func (p *Peer) SendError(err error, ref uint64) error {
+	return p.Send(models.MessageError, models.ErrorPayload{Message: err.Error(), Ref: ref})
+}
+
+// Decode parses an inbound message into an envelope
+// Bare compatibility messages become direction or command envelopes
+func (p *Peer) Decode(msg []byte) (models.Envelope, error) {
+	if p.envelope {
+		env, err := p.codec.decode(msg)
+		if err != nil {
+			return env, fmt.Errorf("invalid message: %v", err)
+		}
+		return env, nil
+	}
+
+	var env models.Envelope
+	var bare struct {
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal(msg, &bare); err != nil {
+		return env, fmt.Errorf("invalid message: %v", err)
+	}
+	env.Type = models.MessageDirection
+	if bare.Command != "" {
+		env.Type = models.MessageCommand
+	}
+	env.Payload = msg // Bare messages already have the payload's shape
+	return env, nil
+}
+
+// ReadMessage reads the next message from the connection
+// Every message counts as activity for the idle timeout and the heartbeat
+func (p *Peer) ReadMessage() ([]byte, error) {
+	_, msg, err := p.conn.ReadMessage()
+	if err != nil {
+		p.setReason(readReason(err))
+		return nil, err
+	}
+
+	p.extendRead()
+	p.statusMutex.Lock()
+	p.lastInput = time.Now()
+	p.statusMutex.Unlock()
+	return msg, nil
+}
+
+// Reject closes the connection with a policy violation, telling the client why
+// The error and close frame follow whatever is already queued
+func (p *Peer) Reject(reason error) {
+	p.setReason(ReasonRejected)
+	if p.SendError(reason, 0) != nil {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason.Error())
+	p.enqueue(outbound{close: msg})
+}
+
+// closed reports whether Close has been called
+func (p *Peer) closed() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close closes the underlying connection and stops the heartbeat and writer
+func (p *Peer) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	return p.conn.Close()
+}
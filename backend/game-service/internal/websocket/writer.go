@@ -0,0 +1,82 @@
+package websocket
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// SlowPolicy decides what happens when a peer's send queue is full
+type SlowPolicy int
+
+// Policies for slow consumers
+const (
+	DropStates     SlowPolicy = iota // Skip game states until the queue drains; other messages still disconnect
+	DisconnectSlow                   // Disconnect the peer as soon as its queue is full
+)
+
+// SendQueue configures the queue between the game loop and a peer's writer
+type SendQueue struct {
+	Size   int        // Messages that may wait for the writer
+	Policy SlowPolicy // What to do once that many are waiting
+}
+
+// ReasonSlow is recorded for peers that could not keep up with their queue
+const ReasonSlow DisconnectReason = "slow consumer"
+
+// errDropped reports a state skipped because the peer's queue was full
+var errDropped = errors.New("state dropped")
+
+// outbound is a message waiting for the writer
+type outbound struct {
+	t       models.MessageType
+	payload interface{}
+	close   []byte // Close frame to send before closing the connection; nil for messages
+}
+
+// enqueue hands a message to the writer without waiting for it
+// Messages to a closed peer are discarded. When the queue is full a state is
+// dropped under DropStates; anything else disconnects the peer
+// Callers must hold the mutex
+func (p *Peer) enqueue(msg outbound) error {
+	select {
+	case p.queue <- msg:
+		return nil
+	case <-p.done:
+		return nil
+	default:
+	}
+
+	if p.sendQueue.Policy == DropStates && (msg.t == models.MessageState || msg.t == models.MessageDelta) {
+		return errDropped
+	}
+	p.setReason(ReasonSlow)
+	p.Close()
+	return errors.New("send queue full")
+}
+
+// writeLoop writes queued messages until the connection closes
+// It is the only goroutine writing data frames, so a slow client holds up
+// nobody but itself
+func (p *Peer) writeLoop() {
+	for {
+		select {
+		case msg := <-p.queue:
+			if msg.close != nil {
+				p.conn.WriteControl(websocket.CloseMessage, msg.close, time.Now().Add(time.Second))
+				p.Close()
+				return
+			}
+			if err := p.write(msg.t, msg.payload); err != nil {
+				log.Printf("Error writing to client: %v", err)
+				p.Close()
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
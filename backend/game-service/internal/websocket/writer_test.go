@@ -0,0 +1,192 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/snake-game/game-service/internal/game"
+	"github.com/snake-game/game-service/pkg/models"
+)
+
+// peerPair connects a client to a peer whose writer has not been started, so
+// nothing leaves its queue until the test starts it
+func peerPair(t *testing.T, subprotocol string, sendQueue SendQueue) (*Peer, *websocket.Conn) {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{Subprotocols: Subprotocols}
+	peers := make(chan *Peer, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		peers <- newPeer(conn, Heartbeat{}, sendQueue)
+	}))
+	t.Cleanup(ts.Close)
+
+	dialer := websocket.Dialer{Subprotocols: []string{subprotocol}}
+	client, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	p := <-peers
+	t.Cleanup(func() { p.Close() })
+	return p, client
+}
+
+// readType reads the next envelope from the client and returns its type
+func readType(t *testing.T, client *websocket.Conn) models.MessageType {
+	t.Helper()
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var env models.Envelope
+	if err := client.ReadJSON(&env); err != nil {
+		t.Fatal(err)
+	}
+	return env.Type
+}
+
+func TestDropStates(t *testing.T) {
+	p, client := peerPair(t, models.ProtocolV1Delta, SendQueue{Size: 1, Policy: DropStates})
+	g := game.NewGame(models.GameConfig{GridSize: 20, InitialX: 2, InitialY: 2, Seed: 3})
+
+	if err := p.SendState(g.GetState()); err != nil {
+		t.Fatal(err)
+	}
+	g.Update()
+	if err := p.SendState(g.GetState()); err != nil {
+		t.Fatalf("Expected the state to be dropped quietly, got %v", err)
+	}
+
+	// The client missed a state, so the next one cannot be a delta
+	go p.writeLoop()
+	if got := readType(t, client); got != models.MessageState {
+		t.Fatalf("Expected the first keyframe, got %s", got)
+	}
+	g.Update()
+	if err := p.SendState(g.GetState()); err != nil {
+		t.Fatal(err)
+	}
+	if got := readType(t, client); got != models.MessageState {
+		t.Errorf("Expected a keyframe after a dropped state, got %s", got)
+	}
+	g.Update()
+	if err := p.SendState(g.GetState()); err != nil {
+		t.Fatal(err)
+	}
+	if got := readType(t, client); got != models.MessageDelta {
+		t.Errorf("Expected deltas to resume, got %s", got)
+	}
+}
+
+func TestDropStatesKeepsResumeToken(t *testing.T) {
+	p, client := peerPair(t, models.ProtocolV1, SendQueue{Size: 1, Policy: DropStates})
+	g := game.NewGame(models.GameConfig{GridSize: 20, InitialX: 2, InitialY: 2, Seed: 3})
+
+	p.SendState(g.GetState())
+	p.SetResumeToken("token")
+	p.SendState(g.GetState()) // Dropped along with the token
+
+	go p.writeLoop()
+	readType(t, client)
+	p.SendState(g.GetState())
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var env struct {
+		Payload models.GameState `json:"payload"`
+	}
+	if err := client.ReadJSON(&env); err != nil {
+		t.Fatal(err)
+	}
+	if env.Payload.ResumeToken != "token" {
+		t.Errorf("Expected the token to follow the dropped state, got %q", env.Payload.ResumeToken)
+	}
+}
+
+func TestDisconnectSlow(t *testing.T) {
+	p, client := peerPair(t, models.ProtocolV1, SendQueue{Size: 1, Policy: DisconnectSlow})
+	g := game.NewGame(models.GameConfig{GridSize: 20, Seed: 3})
+
+	if err := p.SendState(g.GetState()); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SendState(g.GetState()); err == nil {
+		t.Fatal("Expected a full queue to disconnect the peer")
+	}
+	if p.Reason() != ReasonSlow {
+		t.Errorf("Expected reason %q, got %q", ReasonSlow, p.Reason())
+	}
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := client.ReadMessage(); err == nil {
+		t.Error("Expected the connection to be closed")
+	}
+}
+
+func TestFullQueueDisconnectsOtherMessages(t *testing.T) {
+	p, _ := peerPair(t, models.ProtocolV1, SendQueue{Size: 1, Policy: DropStates})
+
+	p.Send(models.MessageQueue, models.MatchmakingMessage{Type: models.MatchmakingQueued})
+	if err := p.Send(models.MessageMatchFound, models.MatchmakingMessage{Type: models.MatchmakingFound}); err == nil {
+		t.Fatal("Expected a message that cannot be dropped to disconnect the peer")
+	}
+	if p.Reason() != ReasonSlow {
+		t.Errorf("Expected reason %q, got %q", ReasonSlow, p.Reason())
+	}
+}
+
+func TestRejectAfterQueuedMessages(t *testing.T) {
+	p, client := peerPair(t, models.ProtocolV1, SendQueue{Size: 4})
+	go p.writeLoop()
+
+	p.Send(models.MessageQueue, models.MatchmakingMessage{Type: models.MatchmakingQueued})
+	p.Reject(errors.New("go away"))
+
+	if got := readType(t, client); got != models.MessageQueue {
+		t.Errorf("Expected the queued message first, got %s", got)
+	}
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var env struct {
+		Type    models.MessageType  `json:"type"`
+		Payload models.ErrorPayload `json:"payload"`
+	}
+	if err := client.ReadJSON(&env); err != nil {
+		t.Fatal(err)
+	}
+	if env.Type != models.MessageError || env.Payload.Message != "go away" {
+		t.Errorf("Expected the rejection error, got %+v", env)
+	}
+	_, _, err := client.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+		t.Errorf("Expected a policy violation close, got %v", err)
+	}
+}
+
+func TestDisconnectQueued(t *testing.T) {
+	p, client := peerPair(t, models.ProtocolV1, SendQueue{Size: 4})
+
+	// Nothing is written yet, so Disconnect must not wait for the client
+	p.Send(models.MessageQueue, models.MatchmakingMessage{Type: models.MatchmakingQueued})
+	p.Disconnect(ReasonSessionEnded)
+	if p.Reason() != ReasonSessionEnded {
+		t.Errorf("Expected reason %q, got %q", ReasonSessionEnded, p.Reason())
+	}
+
+	go p.writeLoop()
+	if got := readType(t, client); got != models.MessageQueue {
+		t.Errorf("Expected the queued message first, got %s", got)
+	}
+	_, _, err := client.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway || closeErr.Text != string(ReasonSessionEnded) {
+		t.Errorf("Expected a going away close with the reason, got %v", err)
+	}
+}
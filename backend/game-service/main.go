@@ -8,13 +8,23 @@ A modern implementation of the classic Snake game using Go and WebSocket
 package main
 
 import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"log"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,11 +36,30 @@ import (
 
 // Game configuration constants
 const (
-	GRID_SIZE       = 20
+	GRID_WIDTH      = 20
+	GRID_HEIGHT     = 20
 	GAME_TICK_MS    = 200
-	INITIAL_SNAKE_X = 10
-	INITIAL_SNAKE_Y = 10
+	INITIAL_SNAKE_X = GRID_WIDTH / 2
+	INITIAL_SNAKE_Y = GRID_HEIGHT / 2
 	MAX_ENTRIES     = 10
+	MAX_INPUT_QUEUE = 3                // Turns that can wait for upcoming ticks
+	RESUME_GRACE    = 30 * time.Second // How long a dropped game waits for its player by default
+	PING_INTERVAL   = 25 * time.Second // How often each connection is pinged
+	PONG_WAIT       = 60 * time.Second // How long a connection may stay silent, pongs included
+	WRITE_WAIT      = 10 * time.Second // How long a single write may take
+	IDLE_TIMEOUT    = 5 * time.Minute  // How long a player may go without sending anything
+	RESULT_TTL      = 24 * time.Hour   // How long a finished game's result token can be submitted
+	MAX_SEED        = 1<<53 - 1        // Largest seed; JavaScript clients hold seeds up to 53 bits exactly
+)
+
+// Disconnect reasons recorded when a connection ends
+const (
+	DISCONNECT_CLOSED   = "closed"            // The client closed the connection
+	DISCONNECT_TIMEOUT  = "heartbeat timeout" // Nothing, not even a pong, arrived within PONG_WAIT
+	DISCONNECT_READ     = "read error"        // Reading from the connection failed
+	DISCONNECT_WRITE    = "write error"       // A write or ping failed or took too long
+	DISCONNECT_IDLE     = "idle"              // The player sent nothing for IDLE_TIMEOUT
+	DISCONNECT_REPLACED = "replaced"          // A resumed connection took over the game
 )
 
 // Direction constants
@@ -41,6 +70,13 @@ const (
 	RIGHT = "RIGHT"
 )
 
+// Command constants
+const (
+	PAUSE  = "pause"
+	RESUME = "resume"
+	SUBMIT = "submit" // Adds the finished game's score to the leaderboard under playerName
+)
+
 // Point represents a position on the game grid
 type Point struct {
 	X int `json:"x"`
@@ -54,6 +90,24 @@ type GameState struct {
 	Score     int     `json:"score"`
 	GameOver  bool    `json:"gameOver"`
 	Direction string  `json:"direction"`
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Seed      int64   `json:"seed"`
+	Paused    bool    `json:"paused"`
+
+	// Only in the first state after connecting; reconnect with /ws?resume=TOKEN
+	ResumeToken string `json:"resumeToken,omitempty"`
+
+	// Only once the game is over; POST it to /leaderboard or send the submit command
+	ResultToken string `json:"resultToken,omitempty"`
+	Submitted   bool   `json:"submitted,omitempty"` // True once the score is on the leaderboard
+}
+
+// ResultClaims is what a result token vouches for
+type ResultClaims struct {
+	Nonce  string `json:"nonce"`  // Random, so every token can be told apart and used only once
+	Score  int    `json:"score"`  // Final score of the game
+	Issued int64  `json:"issued"` // Unix time the game ended
 }
 
 // ScoreEntry represents a leaderboard entry
@@ -65,11 +119,41 @@ type ScoreEntry struct {
 
 // Game represents a single game instance
 type Game struct {
-	state    GameState
-	mutex    sync.RWMutex
-	ticker   *time.Ticker
-	stopChan chan struct{}
-	conn     *websocket.Conn
+	state      GameState
+	mutex      sync.RWMutex
+	loop       *GameLoop   // Loop that ticks the game
+	conn       *Connection // Player's connection; nil while waiting for the player to reconnect
+	wrapAround bool        // No walls: leaving one edge re-enters on the opposite edge
+	rng        *rand.Rand  // Per-game random source, replayable from state.Seed
+	inputs     []string    // Queued turns, applied one per tick
+	token      string      // Resume token the player reconnects with
+	tokenDue   bool        // True until the resume token has been sent on the current connection
+	expiry     *time.Timer // Closes the game if the player does not reconnect in time
+}
+
+// GameLoop ticks its share of the games one after another on a single goroutine
+// Games are spread across a fixed number of loops rather than each running its own
+type GameLoop struct {
+	games   map[*Game]bool
+	mutex   sync.Mutex
+	started sync.Once
+}
+
+// Connection is a player's WebSocket connection with its liveness checks
+type Connection struct {
+	conn      *websocket.Conn
+	states    chan GameState // Latest state waiting for writeLoop
+	lastInput time.Time      // When the player last sent a message
+	reason    string         // Why the connection ended; the first reason recorded wins
+	mutex     sync.Mutex     // Mutex for lastInput and reason
+	done      chan struct{}  // Closed when the connection ends, stopping keepAlive and writeLoop
+	closeOnce sync.Once
+}
+
+// GameRegistry keeps running games by resume token so dropped players can reconnect
+type GameRegistry struct {
+	games map[string]*Game
+	mutex sync.Mutex
 }
 
 // Leaderboard represents the game's leaderboard
@@ -87,25 +171,58 @@ var upgrader = websocket.Upgrader{
 
 var leaderboard *Leaderboard
 
+var registry = &GameRegistry{games: make(map[string]*Game)}
+
+// gameLoops tick every running game, one loop per CPU
+var gameLoops = newGameLoops(runtime.NumCPU())
+
+// resultSecret signs result tokens; RESULT_SECRET sets it so tokens survive restarts
+var resultSecret = newResultSecret()
+
+// Errors for score submissions that cannot be accepted
+var (
+	errInvalidResult = errors.New("invalid result token")
+	errExpiredResult = errors.New("expired result token")
+	errUsedResult    = errors.New("result token already used")
+)
+
+// Connection timeouts; each can be set with the environment variable of the same name
+var (
+	resumeGrace  = RESUME_GRACE
+	pingInterval = PING_INTERVAL
+	pongWait     = PONG_WAIT
+	writeWait    = WRITE_WAIT
+	idleTimeout  = IDLE_TIMEOUT
+)
+
 // Game methods
-func newGame(conn *websocket.Conn) *Game {
-	return &Game{
+func newGame(conn *Connection) *Game {
+	return newSeededGame(conn, max(time.Now().UnixNano()&MAX_SEED, 1))
+}
+
+// newSeededGame creates a game whose food sequence is fully determined by seed
+func newSeededGame(conn *Connection, seed int64) *Game {
+	g := &Game{
 		state: GameState{
 			Snake:     []Point{{X: INITIAL_SNAKE_X, Y: INITIAL_SNAKE_Y}},
-			Food:      generateFood(),
 			Score:     0,
 			GameOver:  false,
 			Direction: RIGHT,
+			Width:     GRID_WIDTH,
+			Height:    GRID_HEIGHT,
+			Seed:      seed,
 		},
-		conn:     conn,
-		stopChan: make(chan struct{}),
+		conn: conn,
+		rng:  rand.New(rand.NewSource(seed)),
 	}
+	g.state.Food = g.generateFood()
+	return g
 }
 
-func generateFood() Point {
+func (g *Game) generateFood() Point {
 	return Point{
-		X: rand.Intn(GRID_SIZE),
-		Y: rand.Intn(GRID_SIZE),
+		X: g.rng.Intn(GRID_WIDTH),
+		Y: g.rng.Intn(GRID_HEIGHT),
 	}
 }
 
@@ -113,24 +230,30 @@ func (g *Game) handleDirection(direction string) {
 	g.mutex.Lock()
 	defer g.mutex.Unlock()
 
-	if g.state.GameOver {
+	if g.state.GameOver || g.state.Paused || len(g.inputs) >= MAX_INPUT_QUEUE {
 		return
 	}
 
-	isValidTurn := true
+	// Check against the direction the snake will have after the queued turns
+	last := g.state.Direction
+	if len(g.inputs) > 0 {
+		last = g.inputs[len(g.inputs)-1]
+	}
+
+	isValidTurn := false
 	switch direction {
 	case UP:
-		isValidTurn = g.state.Direction != DOWN
+		isValidTurn = last != DOWN
 	case DOWN:
-		isValidTurn = g.state.Direction != UP
+		isValidTurn = last != UP
 	case LEFT:
-		isValidTurn = g.state.Direction != RIGHT
+		isValidTurn = last != RIGHT
 	case RIGHT:
-		isValidTurn = g.state.Direction != LEFT
+		isValidTurn = last != LEFT
 	}
 
-	if isValidTurn {
-		g.state.Direction = direction
+	if isValidTurn && direction != last {
+		g.inputs = append(g.inputs, direction)
 	}
 }
 
@@ -138,15 +261,25 @@ func (g *Game) update() {
 	g.mutex.Lock()
 	defer g.mutex.Unlock()
 
-	if g.state.GameOver {
+	if g.state.GameOver || g.state.Paused {
 		return
 	}
 
+	// Apply the next queued turn
+	if len(g.inputs) > 0 {
+		g.state.Direction = g.inputs[0]
+		g.inputs = g.inputs[1:]
+	}
+
 	head := g.state.Snake[0]
 	newHead := g.getNextPosition(head)
+	if g.wrapAround {
+		newHead = wrapPosition(newHead)
+	}
 
 	if g.isCollision(newHead) {
 		g.state.GameOver = true
+		g.state.ResultToken = issueResultToken(g.state.Score)
 		log.Printf("🎮 Game Over! Final Score: %d", g.state.Score)
 		return
 	}
@@ -154,7 +287,7 @@ func (g *Game) update() {
 	if newHead.X == g.state.Food.X && newHead.Y == g.state.Food.Y {
 		g.state.Score++
 		log.Printf("🍎 Food eaten! Score increased to: %d", g.state.Score)
-		g.state.Food = generateFood()
+		g.state.Food = g.generateFood()
 		log.Printf("🎯 New food position: (%d, %d)", g.state.Food.X, g.state.Food.Y)
 	} else {
 		g.state.Snake = g.state.Snake[:len(g.state.Snake)-1]
@@ -162,6 +295,27 @@ func (g *Game) update() {
 	g.state.Snake = append([]Point{newHead}, g.state.Snake...)
 }
 
+// handleCommand pauses or resumes the game
+func (g *Game) handleCommand(command string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	switch command {
+	case PAUSE:
+		if !g.state.GameOver {
+			g.state.Paused = true
+			log.Printf("⏸️ Game paused - Score: %d", g.state.Score)
+		}
+	case RESUME:
+		if g.state.Paused {
+			g.state.Paused = false
+			log.Printf("▶️ Game resumed - Score: %d", g.state.Score)
+		}
+	default:
+		log.Printf("❌ Unknown command: %s", command)
+	}
+}
+
 func (g *Game) getNextPosition(current Point) Point {
 	switch g.state.Direction {
 	case UP:
@@ -177,8 +331,16 @@ func (g *Game) getNextPosition(current Point) Point {
 	}
 }
 
+// wrapPosition maps a position that left the grid onto the opposite edge
+func wrapPosition(pos Point) Point {
+	return Point{
+		X: (pos.X%GRID_WIDTH + GRID_WIDTH) % GRID_WIDTH,
+		Y: (pos.Y%GRID_HEIGHT + GRID_HEIGHT) % GRID_HEIGHT,
+	}
+}
+
 func (g *Game) isCollision(pos Point) bool {
-	if pos.X < 0 || pos.X >= GRID_SIZE || pos.Y < 0 || pos.Y >= GRID_SIZE {
+	if pos.X < 0 || pos.X >= GRID_WIDTH || pos.Y < 0 || pos.Y >= GRID_HEIGHT {
 		return true
 	}
 
@@ -197,30 +359,409 @@ func (g *Game) getState() GameState {
 	return g.state
 }
 
+// start hands the game to the game loop with the fewest games
 func (g *Game) start() {
-	g.ticker = time.NewTicker(GAME_TICK_MS * time.Millisecond)
-
-	go func() {
-		for {
-			select {
-			case <-g.ticker.C:
-				g.update()
-				if err := g.conn.WriteJSON(g.getState()); err != nil {
-					log.Printf("Error sending state: %v", err)
-					return
-				}
-			case <-g.stopChan:
+	g.loop = gameLoops[0]
+	for _, loop := range gameLoops[1:] {
+		if loop.size() < g.loop.size() {
+			g.loop = loop
+		}
+	}
+	g.loop.add(g)
+}
+
+func (g *Game) stop() {
+	if g.loop != nil {
+		g.loop.remove(g)
+	}
+}
+
+// send queues the current state for the player's connection, if one is attached
+// The first state on each connection carries the resume token
+func (g *Game) send() {
+	g.mutex.Lock()
+	conn, state := g.conn, g.state
+	if g.tokenDue {
+		state.ResumeToken = g.token
+		g.tokenDue = false
+	}
+	g.mutex.Unlock()
+
+	if conn != nil {
+		conn.queue(state)
+	}
+}
+
+// attach makes conn the player's connection and returns the one it replaces
+func (g *Game) attach(conn *Connection) *Connection {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	old := g.conn
+	g.conn = conn
+	g.tokenDue = true
+	if g.expiry != nil {
+		g.expiry.Stop()
+		g.expiry = nil
+	}
+	return old
+}
+
+// detach drops the player's connection
+// The game is paused for resumeGrace so the player can reconnect; a finished
+// game is closed straight away
+func (g *Game) detach(conn *Connection) {
+	g.mutex.Lock()
+	if g.conn != conn {
+		g.mutex.Unlock()
+		return // Another connection has taken over the game
+	}
+	g.conn = nil
+	wait := !g.state.GameOver && resumeGrace > 0
+	if wait {
+		g.state.Paused = true
+		g.expiry = time.AfterFunc(resumeGrace, func() { registry.expire(g) })
+		log.Printf("⏸️ Game paused for %s while the player reconnects - Score: %d", resumeGrace, g.state.Score)
+	}
+	g.mutex.Unlock()
+
+	if !wait {
+		registry.remove(g)
+	}
+}
+
+// submitScore puts the finished game's score on the leaderboard under playerName
+// It uses the game's own result token, so the score can only be submitted once
+func (g *Game) submitScore(playerName string) error {
+	g.mutex.RLock()
+	token := g.state.ResultToken
+	g.mutex.RUnlock()
+
+	if token == "" {
+		return errors.New("the game is not over")
+	}
+	if playerName == "" {
+		return errors.New("player name is required")
+	}
+	if err := leaderboard.AddScore(playerName, token); err != nil {
+		return err
+	}
+
+	g.mutex.Lock()
+	g.state.Submitted = true
+	g.mutex.Unlock()
+	return nil
+}
+
+// newResultSecret returns a random key for signing result tokens
+func newResultSecret() []byte {
+	b := make([]byte, 32)
+	crand.Read(b)
+	return b
+}
+
+// signResult returns the signature of a result token's encoded claims
+func signResult(body string) []byte {
+	mac := hmac.New(sha256.New, resultSecret)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
+
+// issueResultToken signs the final score of a game that has just ended
+// The token is the encoded claims and their signature, separated by a dot
+func issueResultToken(score int) string {
+	claims, _ := json.Marshal(ResultClaims{Nonce: newResumeToken(), Score: score, Issued: time.Now().Unix()})
+	body := base64.RawURLEncoding.EncodeToString(claims)
+	return body + "." + base64.RawURLEncoding.EncodeToString(signResult(body))
+}
+
+// verifyResultToken checks a result token's signature and age and returns its claims
+// Whether it has been used is up to the leaderboard
+func verifyResultToken(token string) (ResultClaims, error) {
+	var claims ResultClaims
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return claims, errInvalidResult
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, signResult(body)) {
+		return claims, errInvalidResult
+	}
+	data, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil || json.Unmarshal(data, &claims) != nil {
+		return claims, errInvalidResult
+	}
+	if time.Since(time.Unix(claims.Issued, 0)) > RESULT_TTL {
+		return claims, errExpiredResult
+	}
+	return claims, nil
+}
+
+// newResumeToken returns a random token that is hard to guess
+func newResumeToken() string {
+	b := make([]byte, 16)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// GameLoop methods
+func newGameLoops(n int) []*GameLoop {
+	loops := make([]*GameLoop, n)
+	for i := range loops {
+		loops[i] = &GameLoop{games: make(map[*Game]bool)}
+	}
+	return loops
+}
+
+// add starts ticking g, starting the loop with its first game
+func (l *GameLoop) add(g *Game) {
+	l.mutex.Lock()
+	l.games[g] = true
+	l.mutex.Unlock()
+	l.started.Do(func() { go l.run() })
+}
+
+// remove stops ticking g
+func (l *GameLoop) remove(g *Game) {
+	l.mutex.Lock()
+	delete(l.games, g)
+	l.mutex.Unlock()
+}
+
+// size returns the number of games the loop ticks
+func (l *GameLoop) size() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.games)
+}
+
+// run ticks the loop's games every GAME_TICK_MS and logs when it falls behind
+// Sending only queues each state, so a slow player cannot hold up the others
+func (l *GameLoop) run() {
+	interval := GAME_TICK_MS * time.Millisecond
+	next := time.Now().Add(interval)
+	for {
+		time.Sleep(time.Until(next))
+		if lag := time.Since(next); lag > interval/2 {
+			log.Printf("⚠️ Game loop is running %s behind", lag)
+		}
+
+		l.mutex.Lock()
+		games := make([]*Game, 0, len(l.games))
+		for g := range l.games {
+			games = append(games, g)
+		}
+		l.mutex.Unlock()
+
+		for _, g := range games {
+			g.update()
+			g.send()
+		}
+
+		// Skip the ticks that were missed rather than running them in a burst
+		if next = next.Add(interval); next.Before(time.Now()) {
+			next = time.Now().Add(interval)
+		}
+	}
+}
+
+// GameRegistry methods
+func (gr *GameRegistry) add(g *Game) {
+	gr.mutex.Lock()
+	defer gr.mutex.Unlock()
+
+	g.token = newResumeToken()
+	g.tokenDue = true
+	gr.games[g.token] = g
+}
+
+// resume attaches conn to the game with the given token
+// It returns nil if there is no such game; a connection still attached to the
+// game, usually one whose drop has not been noticed yet, is closed
+func (gr *GameRegistry) resume(token string, conn *Connection) *Game {
+	gr.mutex.Lock()
+	g, ok := gr.games[token]
+	var old *Connection
+	if ok {
+		old = g.attach(conn)
+	}
+	gr.mutex.Unlock()
+
+	if old != nil {
+		old.end(DISCONNECT_REPLACED)
+	}
+	return g
+}
+
+// expire closes a dropped game unless its player reconnected in time
+func (gr *GameRegistry) expire(g *Game) {
+	gr.mutex.Lock()
+	g.mutex.RLock()
+	reconnected := g.conn != nil
+	g.mutex.RUnlock()
+	if !reconnected {
+		delete(gr.games, g.token)
+	}
+	gr.mutex.Unlock()
+
+	if !reconnected {
+		log.Printf("🏁 Game closed, the player did not reconnect - Final Score: %d", g.getState().Score)
+		g.stop()
+	}
+}
+
+// remove closes a game straight away
+func (gr *GameRegistry) remove(g *Game) {
+	gr.mutex.Lock()
+	delete(gr.games, g.token)
+	gr.mutex.Unlock()
+	g.stop()
+}
+
+// Connection methods
+func newConnection(conn *websocket.Conn) *Connection {
+	c := &Connection{conn: conn, states: make(chan GameState, 1), lastInput: time.Now(), done: make(chan struct{})}
+	c.extendRead()
+	conn.SetPongHandler(func(string) error {
+		c.extendRead()
+		return nil
+	})
+	return c
+}
+
+// extendRead pushes the read deadline back after hearing from the client
+func (c *Connection) extendRead() {
+	if pongWait > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	}
+}
+
+// writeDeadline returns the deadline for a write starting now; zero means none
+func writeDeadline() time.Time {
+	if writeWait <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(writeWait)
+}
+
+// touch records a message from the player
+func (c *Connection) touch() {
+	c.extendRead()
+	c.mutex.Lock()
+	c.lastInput = time.Now()
+	c.mutex.Unlock()
+}
+
+// queue hands a state to writeLoop, replacing one it has not written yet
+// A resume token in the replaced state is carried over
+func (c *Connection) queue(state GameState) {
+	select {
+	case old := <-c.states:
+		if state.ResumeToken == "" {
+			state.ResumeToken = old.ResumeToken
+		}
+	default:
+	}
+	select {
+	case c.states <- state:
+	default: // Another state got in first; the next tick replaces it
+	}
+}
+
+// writeLoop writes queued states until the connection ends
+func (c *Connection) writeLoop() {
+	for {
+		select {
+		case state := <-c.states:
+			c.conn.SetWriteDeadline(writeDeadline())
+			if err := c.conn.WriteJSON(state); err != nil {
+				log.Printf("Error sending state: %v", err) // The read loop notices the drop
+				c.end(DISCONNECT_WRITE)
 				return
 			}
+		case <-c.done:
+			return
 		}
-	}()
+	}
 }
 
-func (g *Game) stop() {
-	if g.ticker != nil {
-		g.ticker.Stop()
+// keepAlive pings the client and ends the connection once the player has
+// been idle for idleTimeout. It runs until the connection ends
+func (c *Connection) keepAlive() {
+	var pings, idleChecks <-chan time.Time
+	if pingInterval > 0 {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		pings = ticker.C
+	}
+	if idleTimeout > 0 {
+		ticker := time.NewTicker(idleTimeout / 2)
+		defer ticker.Stop()
+		idleChecks = ticker.C
+	}
+
+	for {
+		select {
+		case <-pings:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, writeDeadline()); err != nil {
+				c.end(DISCONNECT_WRITE)
+				return
+			}
+		case now := <-idleChecks:
+			c.mutex.Lock()
+			idle := now.Sub(c.lastInput) > idleTimeout
+			c.mutex.Unlock()
+			if idle {
+				c.end(DISCONNECT_IDLE)
+				return
+			}
+		case <-c.done:
+			return
+		}
 	}
-	close(g.stopChan)
+}
+
+// setReason records why the connection ended, unless a reason is already known
+func (c *Connection) setReason(reason string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.reason == "" {
+		c.reason = reason
+	}
+}
+
+// getReason returns why the connection ended
+func (c *Connection) getReason() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.reason
+}
+
+// end closes the connection, telling the client why
+func (c *Connection) end(reason string) {
+	c.setReason(reason)
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, reason)
+	c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	c.close()
+}
+
+// close closes the connection and stops keepAlive and writeLoop
+func (c *Connection) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+	c.conn.Close()
+}
+
+// readReason classifies the error that ended a read
+func readReason(err error) string {
+	var closeErr *websocket.CloseError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &closeErr):
+		return DISCONNECT_CLOSED
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return DISCONNECT_TIMEOUT
+	}
+	return DISCONNECT_READ
 }
 
 // Leaderboard methods
@@ -320,6 +861,20 @@ func InitLeaderboard() error {
 	}
 	log.Println("✅ Score index created/verified")
 
+	// Result tokens already submitted, kept until they would have expired anyway
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS used_results (
+			nonce TEXT PRIMARY KEY,
+			issued INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		log.Printf("❌ Error creating used results table: %v", err)
+		db.Close()
+		return err
+	}
+	log.Println("✅ Used results table created/verified")
+
 	leaderboard = &Leaderboard{
 		db: db,
 	}
@@ -354,8 +909,17 @@ func InitLeaderboard() error {
 	return nil
 }
 
-func (l *Leaderboard) AddScore(playerName string, score int) error {
+// AddScore adds the score a result token vouches for under playerName
+// Only a valid token that has not been used before is accepted, so every score
+// comes from a game the server ran and is counted once
+func (l *Leaderboard) AddScore(playerName string, resultToken string) error {
 	log.Println("=== Starting Score Addition ===")
+	claims, err := verifyResultToken(resultToken)
+	if err != nil {
+		log.Printf("❌ Rejected result token: %v", err)
+		return err
+	}
+	score := claims.Score
 	log.Printf("👤 Player: %s, Score: %d", playerName, score)
 
 	l.mutex.Lock()
@@ -368,6 +932,25 @@ func (l *Leaderboard) AddScore(playerName string, score int) error {
 	}
 	defer tx.Rollback()
 
+	// Each token counts once; tokens old enough to have expired are forgotten
+	var used bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM used_results WHERE nonce = ?)", claims.Nonce).Scan(&used); err != nil {
+		log.Printf("❌ Error checking result token: %v", err)
+		return err
+	}
+	if used {
+		log.Printf("❌ Rejected result token: %v", errUsedResult)
+		return errUsedResult
+	}
+	if _, err := tx.Exec("INSERT INTO used_results (nonce, issued) VALUES (?, ?)", claims.Nonce, claims.Issued); err != nil {
+		log.Printf("❌ Error recording result token: %v", err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM used_results WHERE issued < ?", time.Now().Add(-RESULT_TTL).Unix()); err != nil {
+		log.Printf("❌ Error pruning used result tokens: %v", err)
+		return err
+	}
+
 	// Verify database connection
 	if err := l.db.Ping(); err != nil {
 		log.Printf("❌ Database connection error before insert: %v", err)
@@ -522,52 +1105,108 @@ func CloseLeaderboard() {
 // HTTP handlers
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	log.Println("🎮 New game session starting")
-	conn, err := upgrader.Upgrade(w, r, nil)
+	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("❌ Error upgrading connection: %v", err)
 		return
 	}
-	defer conn.Close()
+	conn := newConnection(ws)
+	defer conn.close()
+	go conn.keepAlive()
+	go conn.writeLoop()
+
+	var game *Game
+	if token := r.URL.Query().Get("resume"); token != "" {
+		// "?resume=TOKEN" reconnects to a game whose connection dropped
+		if game = registry.resume(token, conn); game == nil {
+			log.Printf("❌ Unknown or expired resume token")
+			msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "unknown or expired resume token")
+			ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+			return
+		}
+		log.Printf("🔌 Game resumed - Score: %d", game.getState().Score)
+	} else {
+		// "?seed=N" replays the food sequence of an earlier game; 0 picks a fresh seed
+		if seed, err := strconv.ParseInt(r.URL.Query().Get("seed"), 10, 64); err == nil && seed > 0 && seed <= MAX_SEED {
+			game = newSeededGame(conn, seed)
+		} else {
+			game = newGame(conn)
+		}
 
-	game := newGame(conn)
-	defer game.stop()
+		// "?wrap=true" selects the no-walls mode
+		if wrap, err := strconv.ParseBool(r.URL.Query().Get("wrap")); err == nil {
+			game.wrapAround = wrap
+		}
 
-	log.Printf("🎮 Initial game state - Score: %d, Snake Length: %d",
-		game.state.Score, len(game.state.Snake))
+		log.Printf("🎮 Initial game state - Score: %d, Snake Length: %d",
+			game.state.Score, len(game.state.Snake))
 
-	if err := conn.WriteJSON(game.getState()); err != nil {
-		log.Printf("❌ Error sending initial state: %v", err)
-		return
+		registry.add(game)
+		game.start()
 	}
+	defer game.detach(conn)
 
-	game.start()
+	game.send()
 
 	for {
 		var msg struct {
-			Direction string `json:"direction"`
+			Direction  string `json:"direction"`
+			Command    string `json:"command"`
+			PlayerName string `json:"playerName"` // Name to submit the score under
 		}
 
-		if err := conn.ReadJSON(&msg); err != nil {
+		if err := conn.conn.ReadJSON(&msg); err != nil {
+			conn.setReason(readReason(err))
 			if game.state.GameOver {
 				log.Printf("🏁 Game session ended - Final Score: %d", game.state.Score)
 			} else {
 				log.Printf("❌ Error reading message: %v", err)
 			}
+			log.Printf("🔌 Connection closed (%s)", conn.getReason())
 			break
 		}
+		conn.touch()
 
+		if msg.Command == SUBMIT {
+			if err := game.submitScore(msg.PlayerName); err != nil {
+				log.Printf("❌ Error submitting score: %v", err)
+			} else {
+				game.send() // Tell the player straight away
+			}
+			continue
+		}
+		if msg.Command != "" {
+			game.handleCommand(msg.Command)
+			continue
+		}
 		game.handleDirection(msg.Direction)
 	}
 }
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
-
 	if err := InitLeaderboard(); err != nil {
 		log.Fatalf("Failed to initialize leaderboard: %v", err)
 	}
 	defer CloseLeaderboard()
 
+	// Timeouts can be overridden with durations such as "2m"; "0" turns a check off
+	for name, value := range map[string]*time.Duration{
+		"RESUME_GRACE":  &resumeGrace,
+		"PING_INTERVAL": &pingInterval,
+		"PONG_WAIT":     &pongWait,
+		"WRITE_WAIT":    &writeWait,
+		"IDLE_TIMEOUT":  &idleTimeout,
+	} {
+		if d, err := time.ParseDuration(os.Getenv(name)); err == nil {
+			*value = d
+		}
+	}
+	if secret := os.Getenv("RESULT_SECRET"); secret != "" {
+		resultSecret = []byte(secret)
+	} else {
+		log.Println("⚠️ RESULT_SECRET is not set; result tokens will not survive a restart")
+	}
+
 	router := mux.NewRouter()
 
 	// CORS middleware with detailed logging
@@ -599,8 +1238,8 @@ func main() {
 		log.Printf("📝 Received POST request to /leaderboard")
 
 		var submission struct {
-			PlayerName string `json:"playerName"`
-			Score      int    `json:"score"`
+			PlayerName  string `json:"playerName"`
+			ResultToken string `json:"resultToken"` // Issued with the final state of the game
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&submission); err != nil {
@@ -609,7 +1248,7 @@ func main() {
 			return
 		}
 
-		log.Printf("📊 Received score submission: player=%s, score=%d", submission.PlayerName, submission.Score)
+		log.Printf("📊 Received score submission: player=%s", submission.PlayerName)
 
 		if submission.PlayerName == "" {
 			log.Printf("❌ Error: Player name is empty")
@@ -617,13 +1256,20 @@ func main() {
 			return
 		}
 
-		if err := leaderboard.AddScore(submission.PlayerName, submission.Score); err != nil {
+		if err := leaderboard.AddScore(submission.PlayerName, submission.ResultToken); err != nil {
 			log.Printf("❌ Error adding score: %v", err)
-			http.Error(w, "Failed to save score", http.StatusInternalServerError)
+			switch err {
+			case errInvalidResult, errExpiredResult:
+				http.Error(w, err.Error(), http.StatusForbidden)
+			case errUsedResult:
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				http.Error(w, "Failed to save score", http.StatusInternalServerError)
+			}
 			return
 		}
 
-		log.Printf("✅ Successfully added score for %s: %d", submission.PlayerName, submission.Score)
+		log.Printf("✅ Successfully added score for %s", submission.PlayerName)
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 	}).Methods("POST", "OPTIONS")
@@ -1,7 +1,17 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 // TestNewGame verifies that a new game is properly initialized
@@ -33,8 +43,6 @@ func TestNewGame(t *testing.T) {
 
 // TestHandleDirection verifies direction change logic
 func TestHandleDirection(t *testing.T) {
-	game := newGame(nil)
-
 	// Test valid direction changes
 	testCases := []struct {
 		current  string
@@ -48,8 +56,10 @@ func TestHandleDirection(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
+		game := newGame(nil)
 		game.state.Direction = tc.current
 		game.handleDirection(tc.new)
+		game.update() // Turns are queued and applied on the next tick
 		if game.state.Direction != tc.expected {
 			t.Errorf("From %s, changing to %s: expected %s, got %s",
 				tc.current, tc.new, tc.expected, game.state.Direction)
@@ -64,9 +74,9 @@ func TestCollisionDetection(t *testing.T) {
 	// Test wall collisions
 	wallPositions := []Point{
 		{X: -1, Y: 0},
-		{X: GRID_SIZE, Y: 0},
+		{X: GRID_WIDTH, Y: 0},
 		{X: 0, Y: -1},
-		{X: 0, Y: GRID_SIZE},
+		{X: 0, Y: GRID_HEIGHT},
 	}
 
 	for _, pos := range wallPositions {
@@ -146,3 +156,239 @@ func TestGameOver(t *testing.T) {
 		t.Error("Game should be over after self collision")
 	}
 }
+
+// TestWrapAround verifies the no-walls mode re-enters on the opposite edge
+func TestWrapAround(t *testing.T) {
+	game := newGame(nil)
+	game.wrapAround = true
+
+	game.state.Snake = []Point{{X: 0, Y: 3}}
+	game.state.Food = Point{X: 10, Y: 10}
+	game.state.Direction = LEFT
+	game.update()
+
+	if game.state.GameOver {
+		t.Fatal("Game should not end at the wall in wrap-around mode")
+	}
+	if head := game.state.Snake[0]; head.X != GRID_WIDTH-1 || head.Y != 3 {
+		t.Errorf("Expected head at (%d,3), got (%d,%d)", GRID_WIDTH-1, head.X, head.Y)
+	}
+}
+
+// TestSeededGame verifies that the same seed produces the same food sequence
+func TestSeededGame(t *testing.T) {
+	first := newSeededGame(nil, 42)
+	second := newSeededGame(nil, 42)
+
+	if first.state.Seed != 42 {
+		t.Errorf("Expected seed 42 in state, got %d", first.state.Seed)
+	}
+
+	for i := 0; i < 50; i++ {
+		if first.state.Food != second.state.Food {
+			t.Fatalf("Food %d differs between games with the same seed", i)
+		}
+		first.state.Food = first.generateFood()
+		second.state.Food = second.generateFood()
+	}
+
+	// Fresh seeds stay within what JavaScript numbers hold exactly
+	if seed := newGame(nil).state.Seed; seed <= 0 || seed > MAX_SEED {
+		t.Errorf("Expected a fresh seed within 53 bits, got %d", seed)
+	}
+}
+
+// TestPauseResume verifies that a paused game is frozen until resumed
+func TestPauseResume(t *testing.T) {
+	game := newGame(nil)
+	game.state.Snake = []Point{{X: 5, Y: 5}}
+	game.state.Food = Point{X: 15, Y: 15}
+
+	game.handleCommand(PAUSE)
+	game.update()
+	game.handleDirection(UP)
+
+	if !game.state.Paused {
+		t.Fatal("Game should be paused")
+	}
+	if head := game.state.Snake[0]; head.X != 5 || head.Y != 5 {
+		t.Errorf("Snake should not move while paused, got (%d,%d)", head.X, head.Y)
+	}
+	if game.state.Direction != RIGHT {
+		t.Errorf("Direction should not change while paused, got %s", game.state.Direction)
+	}
+
+	game.handleCommand(RESUME)
+	game.update()
+
+	if game.state.Paused {
+		t.Error("Game should be resumed")
+	}
+	if head := game.state.Snake[0]; head.X != 6 || head.Y != 5 {
+		t.Errorf("Snake should move after resume, got (%d,%d)", head.X, head.Y)
+	}
+}
+
+// TestQueuedDoubleTurn verifies that two quick turns within one tick are both applied
+func TestQueuedDoubleTurn(t *testing.T) {
+	game := newGame(nil)
+	game.state.Snake = []Point{{X: 10, Y: 10}, {X: 9, Y: 10}, {X: 8, Y: 10}}
+	game.state.Food = Point{X: 0, Y: 0}
+
+	game.handleDirection(UP)
+	game.handleDirection(LEFT)
+	game.update()
+	game.update()
+
+	if game.state.GameOver {
+		t.Fatal("Quick double turn should not kill the snake")
+	}
+	if head := game.state.Snake[0]; head.X != 9 || head.Y != 9 {
+		t.Errorf("Expected head at (9,9), got (%d,%d)", head.X, head.Y)
+	}
+}
+
+// TestResumeGame verifies that a dropped game waits for its player to reconnect
+func TestResumeGame(t *testing.T) {
+	game := newGame(nil)
+	registry.add(game)
+
+	game.detach(nil)
+	if !game.getState().Paused {
+		t.Error("Dropped game should be paused")
+	}
+	if resumed := registry.resume(game.token, nil); resumed != game {
+		t.Fatal("Dropped game should be resumable with its token")
+	}
+	if !game.tokenDue {
+		t.Error("Resumed connection should be sent the token again")
+	}
+
+	defer func(grace time.Duration) { resumeGrace = grace }(resumeGrace)
+	resumeGrace = 10 * time.Millisecond
+	game.detach(nil)
+	time.Sleep(50 * time.Millisecond)
+	if registry.resume(game.token, nil) != nil {
+		t.Error("Game should be closed once the grace period is over")
+	}
+}
+
+// TestIdleDisconnect verifies that a player who sends nothing is disconnected
+func TestIdleDisconnect(t *testing.T) {
+	defer func(idle time.Duration) { idleTimeout = idle }(idleTimeout)
+	idleTimeout = 100 * time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(handleWebSocket))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) || closeErr.Text != DISCONNECT_IDLE {
+				t.Errorf("Expected to be disconnected as idle, got %v", err)
+			}
+			return
+		}
+	}
+}
+
+// useTestLeaderboard opens a leaderboard in a temporary directory for one test
+func useTestLeaderboard(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := InitLeaderboard(); err != nil {
+		t.Fatalf("InitLeaderboard: %v", err)
+	}
+	t.Cleanup(func() {
+		CloseLeaderboard()
+		leaderboard = nil
+	})
+}
+
+// TestResultToken verifies that result tokens cannot be forged or reused late
+func TestResultToken(t *testing.T) {
+	token := issueResultToken(7)
+	claims, err := verifyResultToken(token)
+	if err != nil || claims.Score != 7 {
+		t.Fatalf("Expected a valid token for score 7, got %+v, %v", claims, err)
+	}
+
+	// Raising the score breaks the signature
+	forged, _ := json.Marshal(ResultClaims{Nonce: claims.Nonce, Score: 99999, Issued: claims.Issued})
+	_, sig, _ := strings.Cut(token, ".")
+	for _, bad := range []string{"", "nonsense", base64.RawURLEncoding.EncodeToString(forged) + "." + sig} {
+		if _, err := verifyResultToken(bad); err != errInvalidResult {
+			t.Errorf("Expected %q to be invalid, got %v", bad, err)
+		}
+	}
+
+	old, _ := json.Marshal(ResultClaims{Nonce: "old", Score: 1, Issued: time.Now().Add(-RESULT_TTL - time.Minute).Unix()})
+	body := base64.RawURLEncoding.EncodeToString(old)
+	if _, err := verifyResultToken(body + "." + base64.RawURLEncoding.EncodeToString(signResult(body))); err != errExpiredResult {
+		t.Errorf("Expected an old token to have expired, got %v", err)
+	}
+}
+
+// TestAddScoreOnce verifies that only the server's result tokens reach the leaderboard, once each
+func TestAddScoreOnce(t *testing.T) {
+	useTestLeaderboard(t)
+
+	if err := leaderboard.AddScore("cheater", "made.up"); err != errInvalidResult {
+		t.Errorf("Expected a made-up token to be rejected, got %v", err)
+	}
+
+	token := issueResultToken(12)
+	if err := leaderboard.AddScore("player", token); err != nil {
+		t.Fatalf("AddScore: %v", err)
+	}
+	if err := leaderboard.AddScore("player", token); err != errUsedResult {
+		t.Errorf("Expected a used token to be rejected, got %v", err)
+	}
+
+	scores := leaderboard.GetScores()
+	if len(scores) != 1 || scores[0].PlayerName != "player" || scores[0].Score != 12 {
+		t.Errorf("Expected only the token's score on the leaderboard, got %+v", scores)
+	}
+}
+
+// TestSubmitScore verifies that a finished game can submit its own score
+func TestSubmitScore(t *testing.T) {
+	useTestLeaderboard(t)
+
+	game := newGame(nil)
+	if err := game.submitScore("player"); err == nil {
+		t.Error("A running game should have no score to submit")
+	}
+
+	game.state.Snake = []Point{{X: 0, Y: 0}}
+	game.state.Direction = LEFT
+	game.update()
+	state := game.getState()
+	if !state.GameOver || state.ResultToken == "" {
+		t.Fatal("A finished game should carry a result token")
+	}
+
+	if err := game.submitScore("player"); err != nil {
+		t.Fatalf("submitScore: %v", err)
+	}
+	if !game.getState().Submitted {
+		t.Error("The state should show the score was submitted")
+	}
+	if err := game.submitScore("player"); err != errUsedResult {
+		t.Errorf("Expected a second submission to be rejected, got %v", err)
+	}
+}
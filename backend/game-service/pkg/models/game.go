@@ -8,6 +8,28 @@ type Point struct {
 	Y int `json:"y"` // Vertical position (0 is topmost)
 }
 
+// Rect is a rectangle of cells on the board
+// X and Y are the top-left cell; the rectangle covers Width columns and Height rows
+type Rect struct {
+	X      int `json:"x"`      // Leftmost column
+	Y      int `json:"y"`      // Topmost row
+	Width  int `json:"width"`  // Number of columns
+	Height int `json:"height"` // Number of rows
+}
+
+// Contains reports whether p lies inside the rectangle
+func (r Rect) Contains(p Point) bool {
+	return p.X >= r.X && p.X < r.X+r.Width && p.Y >= r.Y && p.Y < r.Y+r.Height
+}
+
+// ShrinkState is the schedule of a shrinking (battle-royale) board
+type ShrinkState struct {
+	Bounds    Rect `json:"bounds"`    // Playable area; cells outside it are deadly walls
+	Next      Rect `json:"next"`      // Playable area after the next shrink; equal to Bounds once fully shrunk
+	TicksLeft int  `json:"ticksLeft"` // Ticks until the next shrink; zero once fully shrunk
+	Every     int  `json:"every"`     // Ticks between shrinks
+}
+
 // Direction represents the movement direction of the snake
 // Using string type for easy JSON serialization and client communication
 type Direction string
@@ -21,22 +43,217 @@ const (
 	Right Direction = "RIGHT" // Snake moves right (increasing X)
 )
 
+// Command is a control message a client can send besides a direction
+type Command string
+
+// Supported client commands
+const (
+	CommandPause   Command = "pause"   // Freeze the game until it is resumed
+	CommandResume  Command = "resume"  // Continue a paused game
+	CommandRestart Command = "restart" // Start a new game on the same connection
+)
+
+// FoodType identifies the kind of food item on the board
+type FoodType string
+
+// Food types and their effects; the exact values live in the game package
+const (
+	FoodNormal FoodType = "normal" // Regular food: small score, grows by one segment, never expires
+	FoodBonus  FoodType = "bonus"  // Rare food worth extra points that disappears after a while
+	FoodShrink FoodType = "shrink" // Food that removes segments from the snake's tail
+)
+
+// FoodItem is a piece of food on the board
+// The position is embedded so the item serializes as {"x", "y", "type", ...}
+type FoodItem struct {
+	Point
+	Type      FoodType `json:"type"`      // Kind of food
+	Score     int      `json:"score"`     // Points awarded when eaten
+	Growth    int      `json:"growth"`    // Segments added when eaten; negative values shrink the snake
+	TicksLeft int      `json:"ticksLeft"` // Ticks until the item expires; zero never expires
+}
+
+// PowerUpType identifies a timed power-up
+type PowerUpType string
+
+// Power-up types; durations live in the game package
+const (
+	PowerUpSpeed  PowerUpType = "speed"  // Shortens the tick interval so the snake moves faster
+	PowerUpSlowMo PowerUpType = "slowmo" // Stretches the tick interval so the snake moves slower
+	PowerUpGhost  PowerUpType = "ghost"  // Lets the snake pass through its own body
+	PowerUpMagnet PowerUpType = "magnet" // Pulls nearby food toward the snake's head
+)
+
+// PowerUp is a power-up waiting on the board to be collected
+type PowerUp struct {
+	Point
+	Type      PowerUpType `json:"type"`      // Effect granted when collected
+	TicksLeft int         `json:"ticksLeft"` // Ticks until the power-up disappears from the board
+}
+
+// ActiveEffect is a collected power-up that is currently applied to the snake
+type ActiveEffect struct {
+	Type      PowerUpType `json:"type"`      // Effect being applied
+	TicksLeft int         `json:"ticksLeft"` // Ticks until the effect wears off
+}
+
+// SnakeState is one player's snake in a shared arena
+type SnakeState struct {
+	Owner     string    `json:"owner"`          // Player controlling the snake
+	Body      []Point   `json:"body"`           // Segments, index 0 is the head; empty once the snake has died
+	Direction Direction `json:"direction"`      // Current direction the snake is moving
+	Score     int       `json:"score"`          // Points from food this snake has eaten
+	Alive     bool      `json:"alive"`          // False once the snake has crashed
+	Team      string    `json:"team,omitempty"` // Team the snake plays for in team mode
+	Ack       uint64    `json:"ack,omitempty"`  // Seq of the owner's last direction input settled in this state
+}
+
+// TeamState is one team's standing in a team arena
+type TeamState struct {
+	Name    string   `json:"name"`    // Team name, also used by clients to pick a colour
+	Score   int      `json:"score"`   // Sum of the food eaten by the team's members
+	Players []string `json:"players"` // Owners of the team's snakes
+}
+
+// RoomStatus is the lifecycle stage of a shared arena
+type RoomStatus string
+
+// Arena lifecycle stages, in order
+const (
+	RoomWaiting   RoomStatus = "waiting"   // Waiting for enough players to join
+	RoomCountdown RoomStatus = "countdown" // Enough players have joined; the game starts when the countdown ends
+	RoomRunning   RoomStatus = "running"   // Snakes are moving
+	RoomFinished  RoomStatus = "finished"  // Every snake has died and the winner is known
+)
+
 // GameState represents the current state of the game
 // This struct is serialized to JSON and sent to the client
 type GameState struct {
-	Snake     []Point   `json:"snake"`     // Array of points representing snake's body, where index 0 is the head
-	Food      Point     `json:"food"`      // Current position of the food that the snake tries to eat
-	Score     int       `json:"score"`     // Player's current score (increases by 1 for each food eaten)
-	GameOver  bool      `json:"gameOver"`  // True when snake collides with wall or itself
-	Direction Direction `json:"direction"` // Current direction the snake is moving
+	ID        string         `json:"id"`        // Unique identifier of the game; a restart starts a game with a new ID
+	Snake     []Point        `json:"snake"`     // Array of points representing snake's body, where index 0 is the head
+	Food      []FoodItem     `json:"food"`      // Food items currently on the board
+	Score     int            `json:"score"`     // Player's current score (increases by the score of each food eaten)
+	GameOver  bool           `json:"gameOver"`  // True when snake collides with wall or itself
+	Direction Direction      `json:"direction"` // Current direction the snake is moving
+	Obstacles []Point        `json:"obstacles"` // Wall cells of the current map; empty on the default open board
+	Width     int            `json:"width"`     // Number of columns on the board
+	Height    int            `json:"height"`    // Number of rows on the board
+	Seed      int64          `json:"seed"`      // Seed of the game's random source; replaying it reproduces the food sequence
+	PowerUps  []PowerUp      `json:"powerUps"`  // Power-ups waiting on the board
+	Effects   []ActiveEffect `json:"effects"`   // Active power-up effects and their remaining ticks
+	Level     int            `json:"level"`     // Current difficulty level, starting at 1
+	Speed     int            `json:"speed"`     // Current tick interval in milliseconds (level and effects applied)
+	Paused    bool           `json:"paused"`    // True while the player has paused the game
+	Tick      uint64         `json:"tick"`      // Ticks the game has advanced; a new game starts again from zero
+	Ack       uint64         `json:"ack"`       // Seq of the last direction input settled (applied or dropped) in this state
+
+	// Battle-royale boards shrink over time
+	Shrink *ShrinkState `json:"shrink,omitempty"` // Current playable area and when it shrinks next
+
+	// Only sent to a solo game's player, in the first state after connecting
+	ResumeToken string `json:"resumeToken,omitempty"` // Token to reconnect with on /ws?resume= if the connection drops
+
+	// Shared arenas list every snake instead of using Snake, Score and Direction
+	Snakes      []SnakeState `json:"snakes,omitempty"`      // Every snake in the arena with its owner
+	Winner      string       `json:"winner,omitempty"`      // Owner who won once the arena is over
+	Status      RoomStatus   `json:"status,omitempty"`      // Lifecycle stage of the arena
+	Countdown   int          `json:"countdown,omitempty"`   // Seconds left before the arena starts while counting down
+	Teams       []TeamState  `json:"teams,omitempty"`       // Team standings in team mode
+	WinningTeam string       `json:"winningTeam,omitempty"` // Team with the highest score once a team arena is over
 }
 
+// MaxSeed is the largest seed a game uses
+// Seeds stay within 53 bits so JavaScript clients can hold them exactly and replay the game
+const MaxSeed int64 = 1<<53 - 1
+
 // GameConfig holds game configuration parameters
 // These settings determine the game's behavior and dimensions
 type GameConfig struct {
-	GridSize int `json:"gridSize"` // Number of cells in both width and height of the game grid
+	GridSize int `json:"gridSize"` // Number of cells in both width and height; used when Width or Height is not set
+	Width    int `json:"width"`    // Number of columns on the board
+	Height   int `json:"height"`   // Number of rows on the board
 	CellSize int `json:"cellSize"` // Pixel size of each grid cell for rendering
-	Speed    int `json:"speed"`    // Game tick interval in milliseconds (lower = faster)
+	Speed    int `json:"speed"`    // Starting tick interval in milliseconds (lower = faster); levels shorten it
 	InitialX int `json:"initialX"` // Starting X position of snake's head
 	InitialY int `json:"initialY"` // Starting Y position of snake's head
+
+	WrapAround bool   `json:"wrapAround"` // When true the board has no walls and the snake re-enters on the opposite edge
+	Map        string `json:"map"`        // Built-in map name ("box", "cross", "maze") or a map file in MapDir; empty for an open board
+	MapDir     string `json:"-"`          // Directory map files are loaded from, set by the server only; empty allows only the built-in maps
+	Seed       int64  `json:"seed"`       // Seed for the game's random source, at most MaxSeed; zero picks a fresh seed for every game
+	FoodItems  int    `json:"foodItems"`  // Number of food items kept on the board; defaults to 1
+	PowerUps   bool   `json:"powerUps"`   // When true timed power-ups spawn on the board
+
+	LevelThresholds []int `json:"levelThresholds"` // Scores at which the next level starts; nil uses the defaults, empty disables levels
+	LevelSpeedStep  int   `json:"levelSpeedStep"`  // Milliseconds taken off the tick interval per level
+	MinSpeed        int   `json:"minSpeed"`        // Fastest tick interval in milliseconds that levels can reach
+
+	MinPlayers int `json:"minPlayers"` // Players a shared arena waits for before it starts; defaults to 1
+	MaxPlayers int `json:"maxPlayers"` // Most players a shared arena accepts; zero means no limit
+	Countdown  int `json:"countdown"`  // Seconds a shared arena counts down before it starts
+
+	Teams        int  `json:"teams"`        // Number of teams players are spread across in a shared arena; zero is free-for-all
+	FriendlyFire bool `json:"friendlyFire"` // When true running into a teammate is as deadly as running into an opponent
+
+	ShrinkEvery int `json:"shrinkEvery"` // Ticks between shrinks of the playable area (battle royale); zero keeps the full board
+	ShrinkMin   int `json:"shrinkMin"`   // Smallest width and height the playable area shrinks to
+
+	ResumeGrace int `json:"resumeGrace"` // Seconds a solo game stays paused for its player to reconnect; zero uses the default, negative disables resuming
+	Workers     int `json:"workers"`     // Game loop workers the sessions are spread across; zero uses one per CPU
+}
+
+// RoomInfo describes a named room as listed by the REST API
+type RoomInfo struct {
+	ID         string     `json:"id"`                  // Identifier used in /rooms/{id} and /ws?room=
+	Name       string     `json:"name"`                // Display name chosen when the room was created
+	Status     RoomStatus `json:"status"`              // Lifecycle stage of the room's arena
+	Players    []string   `json:"players"`             // Players currently in the room
+	MinPlayers int        `json:"minPlayers"`          // Players needed before the countdown starts
+	MaxPlayers int        `json:"maxPlayers"`          // Most players the room accepts; zero means no limit
+	Countdown  int        `json:"countdown,omitempty"` // Seconds left before the room starts while counting down
+	Config     GameConfig `json:"config"`              // Game configuration used by the room
+}
+
+// SessionInfo describes a running session for spectators choosing what to watch
+type SessionInfo struct {
+	ID         string     `json:"id"`               // Game or arena ID to pass to /ws/spectate?session=
+	Arena      string     `json:"arena,omitempty"`  // Arena name; empty for solo games
+	Players    int        `json:"players"`          // Connections controlling a snake
+	Spectators int        `json:"spectators"`       // Connections only watching
+	Score      int        `json:"score"`            // Current score of a solo game; the highest score in an arena
+	Status     RoomStatus `json:"status,omitempty"` // Lifecycle stage of an arena
+	GameOver   bool       `json:"gameOver"`         // True once the game has ended
+}
+
+// WorkerStats reports how many sessions one game loop worker ticks and how
+// late their ticks start
+type WorkerStats struct {
+	Sessions  int     `json:"sessions"`  // Sessions the worker ticks
+	Ticks     uint64  `json:"ticks"`     // Ticks run since the server started
+	MeanLagMs float64 `json:"meanLagMs"` // Average time a tick started after it was due
+	MaxLagMs  float64 `json:"maxLagMs"`  // Longest such delay in the last 30 to 60 seconds
+}
+
+// SchedulerStats reports tick lag for the whole game loop and for each of its workers
+type SchedulerStats struct {
+	WorkerStats               // Totals across every worker
+	Workers     []WorkerStats `json:"workers"` // Each worker on its own
+}
+
+// MatchmakingMessageType identifies a message sent to a player waiting for a match
+type MatchmakingMessageType string
+
+// Matchmaking message types
+const (
+	MatchmakingQueued MatchmakingMessageType = "queue"      // The player's position in the queue changed
+	MatchmakingFound  MatchmakingMessageType = "matchFound" // A match was formed; game states follow on the same connection
+)
+
+// MatchmakingMessage is sent to players on /ws/matchmake until their match starts
+type MatchmakingMessage struct {
+	Type     MatchmakingMessageType `json:"type"`
+	Position int                    `json:"position,omitempty"` // One-based position in the queue
+	Queued   int                    `json:"queued,omitempty"`   // Number of players waiting
+	Session  string                 `json:"session,omitempty"`  // Session the match plays in, as listed by GET /sessions
+	Players  []string               `json:"players,omitempty"`  // Everyone in the match
 }
@@ -0,0 +1,88 @@
+package models
+
+import "encoding/json"
+
+// Subprotocols clients ask for in the Sec-WebSocket-Protocol header during the upgrade
+// Clients that ask for none get the original bare messages: a GameState per
+// frame out and a {direction, command} object per frame in
+const (
+	ProtocolV1      = "snake.v1"       // Envelopes carrying a full GameState every tick
+	ProtocolV1Delta = "snake.v1.delta" // Envelopes carrying periodic keyframes and per-tick deltas
+
+	// Binary variants encode the same messages as MessagePack in binary frames,
+	// with the field names of the JSON encoding; see schema.json
+	ProtocolV1MsgPack      = "snake.v1.msgpack"
+	ProtocolV1DeltaMsgPack = "snake.v1.delta.msgpack"
+)
+
+// MessageType identifies the payload carried by an Envelope
+type MessageType string
+
+// Message types sent by the server
+const (
+	MessageState      MessageType = "state"      // Payload is a GameState; a keyframe in delta mode
+	MessageDelta      MessageType = "delta"      // Payload is a StateDelta against the previous state message
+	MessageError      MessageType = "error"      // Payload is an ErrorPayload
+	MessageQueue      MessageType = "queue"      // Payload is a MatchmakingMessage with the queue position
+	MessageMatchFound MessageType = "matchFound" // Payload is a MatchmakingMessage with the match
+)
+
+// Message types sent by clients
+const (
+	MessageDirection MessageType = "direction" // Payload is a DirectionPayload
+	MessageCommand   MessageType = "command"   // Payload is a CommandPayload
+	MessageResync    MessageType = "resync"    // No payload; asks for a keyframe after a gap in the server's seq
+)
+
+// Envelope wraps every message of the versioned protocol
+// Seq counts the messages sent in each direction on a connection, starting at 1
+type Envelope struct {
+	Type    MessageType     `json:"type"`              // Kind of payload
+	Seq     uint64          `json:"seq"`               // Sender's sequence number for this message
+	Payload json.RawMessage `json:"payload,omitempty"` // Message body, shaped according to Type
+}
+
+// DirectionPayload asks for the player's snake to turn
+// Clients that predict their snake locally number their inputs and replay the
+// ones newer than the Ack of each state they receive
+type DirectionPayload struct {
+	Direction Direction `json:"direction"`
+	Seq       uint64    `json:"seq,omitempty"`  // Client's input sequence number, increasing from 1
+	Tick      uint64    `json:"tick,omitempty"` // Tick of the state the turn was predicted from; informational
+}
+
+// CommandPayload asks for a command such as pause to be applied
+type CommandPayload struct {
+	Command Command `json:"command"`
+}
+
+// ErrorPayload reports a message the server could not handle
+type ErrorPayload struct {
+	Message string `json:"message"`       // What went wrong
+	Ref     uint64 `json:"ref,omitempty"` // Seq of the client message that caused the error, if any
+}
+
+// StateDelta is the change from one state to the next in delta mode
+// It applies to the state built from the previous state or delta message;
+// a client that misses a seq should send a resync and wait for a keyframe
+type StateDelta struct {
+	Snake   *SnakeDelta            `json:"snake,omitempty"`   // Change to a solo game's snake
+	Snakes  []SnakeDelta           `json:"snakes,omitempty"`  // Changes to arena snakes, matched by owner
+	Removed []string               `json:"removed,omitempty"` // Owners of arena snakes that left
+	Fields  map[string]interface{} `json:"fields,omitempty"`  // Other GameState fields that changed, by JSON name; null clears a field
+}
+
+// SnakeDelta is the change to one snake's body and details
+// The new body is Head followed by the old body without its last Trim segments.
+// Details are only sent when they changed, or for a snake new to the client;
+// a detail that is left out keeps its previous value
+type SnakeDelta struct {
+	Owner     string     `json:"owner,omitempty"`     // Owner of an arena snake; new owners are added
+	Head      []Point    `json:"head,omitempty"`      // Segments added at the front, new head first
+	Trim      int        `json:"trim,omitempty"`      // Segments removed from the tail
+	Direction *Direction `json:"direction,omitempty"` // Direction of an arena snake
+	Score     *int       `json:"score,omitempty"`     // Score of an arena snake
+	Alive     *bool      `json:"alive,omitempty"`     // Whether an arena snake is alive
+	Team      *string    `json:"team,omitempty"`      // Team of an arena snake
+	Ack       *uint64    `json:"ack,omitempty"`       // Last input acknowledged for an arena snake
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// jsonFields lists the JSON names of a struct's fields, including embedded ones
+func jsonFields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			names = append(names, jsonFields(field.Type)...)
+			continue
+		}
+		names = append(names, strings.Split(field.Tag.Get("json"), ",")[0])
+	}
+	return names
+}
+
+func TestSchemaCoversModels(t *testing.T) {
+	data, err := os.ReadFile("schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Defs map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Invalid schema: %v", err)
+	}
+
+	for _, v := range []interface{}{
+		Point{}, Rect{}, FoodItem{}, PowerUp{}, ActiveEffect{}, ShrinkState{},
+		SnakeState{}, TeamState{}, GameState{}, SnakeDelta{}, StateDelta{},
+		DirectionPayload{}, CommandPayload{}, ErrorPayload{}, MatchmakingMessage{},
+	} {
+		typ := reflect.TypeOf(v)
+		def, ok := schema.Defs[typ.Name()]
+		if !ok {
+			t.Errorf("Schema has no definition for %s", typ.Name())
+			continue
+		}
+		for _, name := range jsonFields(typ) {
+			if _, ok := def.Properties[name]; !ok {
+				t.Errorf("Schema definition %s is missing %q", typ.Name(), name)
+			}
+		}
+	}
+}
@@ -226,7 +226,8 @@ npm run dev    # Starts dev server on :5173`}
     "snake": [{"x": number, "y": number}],
     "food": {"x": number, "y": number},
     "score": number,
-    "gameOver": boolean
+    "gameOver": boolean,
+    "resultToken"?: string  // Once the game is over
 }`}
                                 </pre>
                             </div>
@@ -236,7 +237,7 @@ npm run dev    # Starts dev server on :5173`}
                                     {`// Request
 {
     "playerName": string,
-    "score": number
+    "resultToken": string  // From the final game state; accepted once
 }
 
 // Response
@@ -14,6 +14,7 @@ interface GameState {
     score: number;       // Player's current score
     gameOver: boolean;   // Whether the game has ended
     direction: string;   // Current direction of snake movement
+    resultToken?: string; // Signed final score, sent once the game is over
 }
 
 // Add new interfaces
@@ -248,7 +249,7 @@ const Game: React.FC = () => {
                 },
                 body: JSON.stringify({
                     playerName: playerName.trim(),
-                    score: gameState.score
+                    resultToken: gameState.resultToken
                 }),
             });
             